@@ -6,18 +6,23 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/textproto"
 	"os"
 	"regexp"
 	"strings"
 
+	"github.com/surlykke/refude/internal/auth"
 	"github.com/surlykke/refude/internal/lib/utils"
+	"github.com/surlykke/refude/internal/lib/xdg"
 )
 
 const operators = "GET POST PATCH DELETE"
@@ -50,10 +55,13 @@ func fail(msg string) {
 }
 
 func usage() {
-	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "Usage: RefudeReq [options] path")
+	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "Usage: refuc [options] path")
+	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "   or: refuc open file-or-url...")
+	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "   or: refuc desktop-url")
 	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "options:")
 	flag.PrintDefaults()
 	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "path: path to resource (eg. /application/firefox.desktop)")
+	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "Set REFUDE_ADDRESS (<host>:<port> or unix:<path>) to talk to a server not listening on the default socket or port")
 }
 
 /**
@@ -63,11 +71,11 @@ func usage() {
  *  - fully read response body
  *  - error, if any, in which case other return values are nil/zero
  */
-func perform(method string, headerMap map[string]string, path string) (string, map[string][]string, []byte, error) {
-	var client = &http.Client{}
-	var url = "http://localhost:7938" + path
+func perform(method string, headerMap map[string]string, path string, requestBody []byte) (string, map[string][]string, []byte, error) {
+	var client, baseUrl = clientFor(serverAddress())
+	var url = baseUrl + path
 
-	var request, err = http.NewRequest(method, url, nil)
+	var request, err = http.NewRequest(method, url, bytes.NewReader(requestBody))
 	if err != nil {
 		return "", nil, nil, err
 	}
@@ -76,7 +84,13 @@ func perform(method string, headerMap map[string]string, path string) (string, m
 		request.Header.Set(key, value)
 	}
 
-	if _, ok := headerMap["Accepts"]; !ok {
+	if request.Header.Get(auth.HeaderName) == "" {
+		if token, err := auth.ReadToken(); err == nil {
+			request.Header.Set(auth.HeaderName, token)
+		}
+	}
+
+	if request.Header.Get("Accept") == "" {
 		request.Header.Set("Accept", "*/*")
 	}
 
@@ -93,9 +107,57 @@ func perform(method string, headerMap map[string]string, path string) (string, m
 	return response.Proto + " " + response.Status, response.Header, body, nil
 }
 
+/*
+* Where to find refude-server: $REFUDE_ADDRESS if set, otherwise the default unix socket if present,
+* otherwise localhost:7938. An address is either <host>:<port> or unix:<path>
+ */
+func serverAddress() string {
+	if address := os.Getenv("REFUDE_ADDRESS"); address != "" {
+		return address
+	} else if _, err := os.Stat(xdg.RefudeSocketPath); err == nil {
+		return "unix:" + xdg.RefudeSocketPath
+	} else {
+		return "localhost:7938"
+	}
+}
+
+/*
+* The url a browser should load to get the desktop page, and with it the token cookie. Eg.:
+*
+*	chromium --app="$(refuc desktop-url)"
+*
+* It holds a nonce, not the token. The nonce is good for one load within 30 seconds.
+ */
+func desktopUrl() {
+	var address = os.Getenv("REFUDE_ADDRESS")
+	if address == "" || strings.HasPrefix(address, "unix:") {
+		address = "localhost:7938"
+	}
+	if status, _, body, err := perform("POST", map[string]string{}, "/desktop/nonce", nil); err != nil {
+		fail(err.Error())
+	} else if !succeeded(status) {
+		fail(status)
+	} else {
+		fmt.Println("http://" + address + "/desktop/login?nonce=" + string(body))
+	}
+}
+
+func clientFor(address string) (*http.Client, string) {
+	if socketPath, ok := strings.CutPrefix(address, "unix:"); ok {
+		var transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+			},
+		}
+		return &http.Client{Transport: transport}, "http://refude"
+	} else {
+		return &http.Client{}, "http://" + address
+	}
+}
+
 // Assumes the resource sitting at collectionPath returns a list of strings
 func getStringlist(collectionPath string) []string {
-	_, _, body, err := perform("GET", nil, collectionPath)
+	_, _, body, err := perform("GET", nil, collectionPath, nil)
 	if err != nil {
 		return nil
 	}
@@ -138,6 +200,9 @@ func completions(argStr string, _ bool) []string {
 		if (!hasX) && strings.HasPrefix("-X", curArg) {
 			comp = append(comp, "-X")
 		}
+		if strings.HasPrefix("-t", curArg) {
+			comp = append(comp, "-t")
+		}
 		if !strings.HasPrefix(curArg, "-") {
 			comp = append(comp, getStringlist("/complete?prefix="+curArg)...)
 		}
@@ -158,18 +223,33 @@ func main() {
 		os.Exit(0)
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "open" {
+		open(os.Args[2:])
+		os.Exit(0)
+	}
+
+	if len(os.Args) == 2 && os.Args[1] == "desktop-url" {
+		desktopUrl()
+		os.Exit(0)
+	}
+
 	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 	flag.Usage = usage
 	var headerMap = make(HeaderMap)
 	flag.Var(&headerMap, "H", "Http header in the form <key>:<value>. May occur multiple times")
 	var method = flag.String("X", "GET", "Http method")
+	var table = flag.Bool("t", false, "Ask for a plain text table rather than json (same as -H Accept:text/plain)")
 	flag.Parse()
 	if flag.NArg() != 1 {
 		flag.Usage()
 		os.Exit(1)
 	}
 
-	protoAndStatus, headers, body, err := perform(*method, headerMap, flag.Arg(0))
+	if *table {
+		headerMap["Accept"] = "text/plain"
+	}
+
+	protoAndStatus, headers, body, err := perform(*method, headerMap, flag.Arg(0), nil)
 
 	if err != nil {
 		fail(err.Error())
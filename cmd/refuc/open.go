@@ -0,0 +1,121 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+/*
+* An xdg-open replacement: Each file or url is given to the default application for its mimetype (for urls
+* x-scheme-handler/<scheme>). Files going to the same application are opened with one request, leaving it to the
+* application's Exec whether it's run once, or once for each.
+ */
+func open(args []string) {
+	if len(args) == 0 {
+		fail("Usage: refuc open file-or-url...")
+	}
+
+	var appIds []string
+	var targets = map[string][]string{}
+	var failed = false
+	for _, arg := range args {
+		if mimetype, target, err := resolve(arg); err != nil {
+			fmt.Fprintln(os.Stderr, arg+":", err)
+			failed = true
+		} else if appId, err := defaultApp(mimetype, 0); err != nil {
+			fmt.Fprintln(os.Stderr, arg+":", err)
+			failed = true
+		} else {
+			if !slices.Contains(appIds, appId) {
+				appIds = append(appIds, appId)
+			}
+			targets[appId] = append(targets[appId], target)
+		}
+	}
+
+	for _, appId := range appIds {
+		var body, _ = json.Marshal(targets[appId])
+		var path = (&url.URL{Path: "/application/" + appId}).EscapedPath()
+		if status, _, respBody, err := perform("POST", map[string]string{"Content-Type": "application/json"}, path, body); err != nil {
+			fmt.Fprintln(os.Stderr, appId+":", err)
+			failed = true
+		} else if !succeeded(status) {
+			fmt.Fprintln(os.Stderr, appId+":", status, string(respBody))
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// Returns the mimetype of arg, and what to give the application
+func resolve(arg string) (string, string, error) {
+	if u, err := url.Parse(arg); err == nil && len(u.Scheme) > 1 && u.Scheme != "file" {
+		return "x-scheme-handler/" + strings.ToLower(u.Scheme), arg, nil
+	} else if err == nil && u.Scheme == "file" {
+		arg = u.Path
+	}
+
+	var osPath, err = filepath.Abs(arg)
+	if err != nil {
+		return "", "", err
+	}
+	var file struct{ Mimetype string }
+	if err := getJson("/mimetypeof", url.Values{"path": {osPath}}, &file); err != nil {
+		return "", "", err
+	} else if file.Mimetype == "" {
+		return "", "", errors.New("mimetype not known")
+	} else {
+		return file.Mimetype, osPath, nil
+	}
+}
+
+// The first application for mimetype, or if none, for what it's a subclass of
+func defaultApp(mimetype string, depth int) (string, error) {
+	var mt struct {
+		Applications []string
+		SubClassOf   []string
+	}
+	if err := getJson("/mimetype/"+mimetype, nil, &mt); err != nil {
+		return "", fmt.Errorf("%s: %w", mimetype, err)
+	} else if len(mt.Applications) > 0 {
+		return mt.Applications[0], nil
+	} else if depth < 5 { // Guard against cycles
+		for _, super := range mt.SubClassOf {
+			if appId, err := defaultApp(super, depth+1); err == nil {
+				return appId, nil
+			}
+		}
+	}
+	return "", errors.New("no application found for " + mimetype)
+}
+
+func getJson(path string, query url.Values, v any) error {
+	var escaped = (&url.URL{Path: path, RawQuery: query.Encode()}).RequestURI()
+	if status, _, body, err := perform("GET", map[string]string{"Accept": "application/json"}, escaped, nil); err != nil {
+		return err
+	} else if !succeeded(status) {
+		return errors.New(status)
+	} else {
+		return json.Unmarshal(body, v)
+	}
+}
+
+// protoAndStatus as returned by perform, eg. 'HTTP/1.1 202 Accepted'
+func succeeded(protoAndStatus string) bool {
+	var fields = strings.Fields(protoAndStatus)
+	return len(fields) > 1 && strings.HasPrefix(fields[1], "2")
+}
@@ -0,0 +1,88 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package main
+
+import (
+	"errors"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const listenFdsStart = 3 // As per sd_listen_fds(3)
+
+/*
+* If we've been socket activated by systemd, we use the sockets handed to us. Otherwise we listen on addresses,
+* each of which is either <host>:<port> or unix:<path>
+ */
+func listen(addresses []string) []net.Listener {
+	if activated := activatedListeners(); len(activated) > 0 {
+		return activated
+	}
+
+	var listeners = make([]net.Listener, 0, len(addresses))
+	for _, address := range addresses {
+		if listener, err := listenOn(address); err != nil {
+			log.Print("Could not listen on ", address, ": ", err)
+		} else {
+			listeners = append(listeners, listener)
+		}
+	}
+	return listeners
+}
+
+func listenOn(address string) (net.Listener, error) {
+	if socketPath, ok := strings.CutPrefix(address, "unix:"); ok {
+		if conn, err := net.Dial("unix", socketPath); err == nil {
+			conn.Close()
+			return nil, errors.New("socket in use")
+		}
+		os.Remove(socketPath) // Stale, from an earlier run
+		if listener, err := net.Listen("unix", socketPath); err != nil {
+			return nil, err
+		} else if err := os.Chmod(socketPath, 0600); err != nil {
+			listener.Close()
+			return nil, err
+		} else {
+			return listener, nil
+		}
+	} else {
+		return net.Listen("tcp", address)
+	}
+}
+
+func activatedListeners() []net.Listener {
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return nil
+	}
+	var nFds, err = strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || nFds < 1 {
+		return nil
+	}
+	var names = strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	os.Unsetenv("LISTEN_PID") // Not to be passed on to launched apps
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	var listeners = make([]net.Listener, 0, nFds)
+	for i := 0; i < nFds; i++ {
+		var name = "LISTEN_FD_" + strconv.Itoa(listenFdsStart+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		var file = os.NewFile(uintptr(listenFdsStart+i), name)
+		if listener, err := net.FileListener(file); err != nil {
+			log.Print("Could not use activated socket ", name, ": ", err)
+		} else {
+			listeners = append(listeners, listener)
+		}
+		file.Close() // FileListener dups the fd
+	}
+	return listeners
+}
@@ -11,16 +11,21 @@ import (
 	"strings"
 
 	"github.com/surlykke/refude/internal/applications"
+	"github.com/surlykke/refude/internal/auth"
 	"github.com/surlykke/refude/internal/browser"
+	"github.com/surlykke/refude/internal/calculator"
 	"github.com/surlykke/refude/internal/desktop"
 	"github.com/surlykke/refude/internal/desktopactions"
 	"github.com/surlykke/refude/internal/file"
 	"github.com/surlykke/refude/internal/icons"
 	"github.com/surlykke/refude/internal/lib/entity"
+	"github.com/surlykke/refude/internal/lib/xdg"
 	"github.com/surlykke/refude/internal/notifications"
 	"github.com/surlykke/refude/internal/options"
 	"github.com/surlykke/refude/internal/power"
+	"github.com/surlykke/refude/internal/recent"
 	"github.com/surlykke/refude/internal/search"
+	"github.com/surlykke/refude/internal/trash"
 	"github.com/surlykke/refude/internal/watch"
 	"github.com/surlykke/refude/internal/wayland"
 	"github.com/surlykke/refude/pkg/bind"
@@ -29,6 +34,16 @@ import (
 func main() {
 	var opts = options.GetOpts()
 
+	if err := xdg.CheckRuntimeDir(); err != nil {
+		log.Fatal("Runtime directory not usable: ", err)
+	}
+	if err := auth.Setup(opts.AllowOrigins); err != nil {
+		log.Fatal("Could not set up token: ", err)
+	}
+
+	entity.HtmlRenderer = desktop.RenderResource
+	entity.ActivationRecorder = search.RecordActivation
+
 	ServeMap(wayland.WindowMap, "/window/")
 	go wayland.Run(opts.IgnoreWinAppIds)
 
@@ -54,35 +69,61 @@ func main() {
 	ServeMap(file.FileMap, "/file/")
 	go file.Run()
 
-	ServeMap(desktopactions.PowerActions, "/start/")
+	ServeMap(trash.TrashMap, "/trash/")
+	bind.Handle("DELETE /trash/{$}", trash.EmptyHandler).Summary("Empty the trash")
+	go trash.Run()
 
-	http.Handle("GET /icon", bind.HandlerFunc(icons.GetHandler, bind.Query("name"), bind.QueryOr("size", "32")))
-	http.Handle("GET /search", bind.HandlerFunc(search.GetHandler, bind.Query("term")))
-	http.Handle("GET /flash", bind.HandlerFunc(notifications.FlashHandler))
-	http.Handle("GET /complete", bind.HandlerFunc(completeHandler, bind.Query("prefix")))
-	http.Handle("GET /desktop/search", bind.HandlerFunc(desktop.SearchHandler, bind.Query("term")))
-	http.Handle("GET /desktop/details", bind.HandlerFunc(desktop.DetailsHandler, bind.Query("path")))
+	ServeMap(recent.RecentMap, "/recent/")
+	go recent.Run()
 
-	http.HandleFunc("GET /watch", watch.ServeHTTP)
-	http.Handle("GET /desktop/", desktop.StaticServer)
-
-	if err := http.ListenAndServe(":7938", nil); err != nil {
-		log.Print("http.ListenAndServe failed:", err)
+	ServeMap(desktopactions.PowerActions, "/start/")
+	ServeMap(calculator.CalculationMap, "/calculation/")
+
+	bind.Handle("GET /icon", icons.GetHandler, bind.Query("name"), bind.QueryOr("size", "32"))
+	bind.Handle("GET /mimetypeof", file.MimetypeHandler, bind.Query("path")).Summary("The mimetype of the file at 'path', indexed or not").Returns(map[string]string{})
+	bind.Handle("GET /search", search.GetHandler, bind.Query("term")).Returns([]search.Ranked{})
+	bind.Handle("GET /frecency", search.GetFrecencyHandler).Returns(map[string]search.Activations{})
+	bind.Handle("DELETE /frecency", search.DeleteFrecencyHandler, bind.QueryOr("path", "")).Summary("Forget what has been learned about 'path', or everything if no path given")
+	bind.Handle("GET /flash", notifications.FlashHandler).Returns(map[string]string{})
+	bind.Handle("GET /complete", completeHandler, bind.Query("prefix")).Returns([]string{})
+	bind.Handle("GET /desktop/search", desktop.SearchHandler, bind.Query("term"), bind.QueryOr("dir", ""))
+	bind.Handle("GET /desktop/details", desktop.DetailsHandler, bind.Query("path"))
+	bind.Handle("GET /openapi.json", bind.OpenAPI)
+
+	bind.HandleHttp("GET /watch", http.HandlerFunc(watch.ServeHTTP)).Summary("Server-sent events. 'resourceChanged' carries {path, kind}, kind being added, updated or removed. Filter resource events with one or more 'prefix' query parameters")
+	bind.HandleHttp("GET /desktop/", desktop.StaticServer)
+	bind.HandleHttp("POST /desktop/nonce", auth.Nonce).Summary("Returns a nonce, good for one login within 30 seconds")
+	bind.HandleHttp("GET /desktop/login", auth.Login).Summary("Sets the token cookie, given a nonce as 'nonce' query parameter, and redirects to /desktop/")
+
+	var listeners = listen(opts.Listen)
+	if len(listeners) == 0 {
+		log.Fatal("Nothing to listen on")
 	}
-
+	var errs = make(chan error, len(listeners))
+	for _, listener := range listeners {
+		log.Print("Serving on ", listener.Addr().Network(), ":", listener.Addr())
+		go func() { errs <- http.Serve(listener, auth.Guard(http.DefaultServeMux)) }()
+	}
+	log.Print("http.Serve failed:", <-errs)
 }
 
 func ServeMap[K cmp.Ordered, V entity.Servable](m *entity.EntityMap[K, V], pathPrefix string) {
 	m.SetPrefix(pathPrefix)
-	http.Handle("GET "+pathPrefix+"{id...}", bind.HandlerFunc(m.DoGet, bind.Path("id")))
-	http.Handle("GET "+pathPrefix+"{$}", bind.HandlerFunc(m.DoGetList))
-	http.Handle("POST "+pathPrefix+"{id...}", bind.HandlerFunc(m.DoPost, bind.Path("id"), bind.QueryOr("action", "")))
+	bind.Handle("GET "+pathPrefix+"{id...}", m.DoGet, bind.Path("id"), bind.HeaderOr("If-None-Match", ""), bind.HeaderOr("Accept", ""), bind.QueryParams()).
+		Returns(*new(V)).
+		Summary("Some resources, eg. directories, take query parameters. Directories: 'offset' and 'limit'")
+	bind.Handle("GET "+pathPrefix+"{$}", m.DoGetList, bind.HeaderOr("If-None-Match", ""), bind.HeaderOr("Accept", ""), bind.QueryParams()).
+		Returns([]V{}).
+		Summary("Filter by giving field values as query parameters. Also supports 'sort', 'limit', 'offset' and 'fields'")
+	bind.Handle("POST "+pathPrefix+"{id...}", m.DoPost, bind.Path("id"), bind.QueryOr("action", ""), bind.QueryOr("arg", ""), bind.BodyOr("json"), bind.HeaderOr("If-Match", ""), bind.HeaderOr("X-Refude-Term", "")).
+		Summary("Give 'arg' (possibly repeated), or a json list as body, to pass arguments, eg. files or urls to an application. X-Refude-Term, if given, is the (percent-encoded) search term that found the entity")
+	bind.Handle("DELETE "+pathPrefix+"{id...}", m.DoDelete, bind.Path("id"), bind.HeaderOr("If-Match", ""))
 }
 
 func completeHandler(prefix string) bind.Response {
 	var filtered = make([]string, 0, 1000)
 	var allPaths = [][]string{
-		{"/flash", "/icon?name=", "/desktop/", "/complete?prefix=", "/search?", "/watch"},
+		{"/flash", "/icon?name=", "/desktop/", "/complete?prefix=", "/search?", "/watch", "/openapi.json", "/frecency"},
 		icons.ThemeMap.GetPaths(),
 		wayland.WindowMap.GetPaths(),
 		applications.AppMap.GetPaths(),
@@ -91,6 +132,8 @@ func completeHandler(prefix string) bind.Response {
 		power.DeviceMap.GetPaths(),
 		browser.TabMap.GetPaths(),
 		browser.BookmarkMap.GetPaths(),
+		recent.RecentMap.GetPaths(),
+		trash.TrashMap.GetPaths(),
 	}
 
 	for _, pathList := range allPaths {
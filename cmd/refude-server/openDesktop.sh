@@ -0,0 +1,18 @@
+#!/usr/bin/env bash
+# Opens the refude desktop page in an app window.
+# The page needs the token cookie to do anything but look, so it is opened
+# through the login url from 'refuc desktop-url', which holds a one-time nonce.
+# Set REFUDE_BROWSER to use another chromium-like browser.
+#
+
+BROWSER=${REFUDE_BROWSER:-chromium}
+
+# Wait for the server to come up
+for i in {1..20}; do
+	if URL=$(refuc desktop-url 2>/dev/null); then
+		exec $BROWSER --app="$URL"
+	fi
+	sleep 0.5
+done
+echo "refude-server not answering" >&2
+exit 1
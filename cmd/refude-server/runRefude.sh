@@ -18,3 +18,8 @@ GTK_LAYER_SHELL_LIB=$(ldconfig -p | grep 'gtk4-layer-shell.so$' | sed 's/.*=>\s*
 LOGFILE=${XDG_RUNTIME_DIR:-/tmp}/RefudeServices.log
 LD_PRELOAD=$GTK_LAYER_SHELL_LIB nohup refude-server $REFUDE_SWITCHES >$LOGFILE 2>$LOGFILE &
 
+
+# With REFUDE_OPEN_DESKTOP set, also open the desktop page, logged in
+if [[ -n "$REFUDE_OPEN_DESKTOP" ]]; then
+	nohup openDesktop.sh >/dev/null 2>&1 &
+fi
@@ -14,7 +14,7 @@ ASSETS_DIR=${XDG_DATA_HOME:-${HOME}/.local/share}/refude
 mkdir -p $GOBIN $BASH_COMPLETION_DIR $FISH_COMPLETION_DIR $HICOLOR_ICON_DIR $ASSETS_DIR
 
 go install ./cmd/refude-server
-cp ./cmd/refude-server/runRefude.sh $GOBIN
+cp ./cmd/refude-server/runRefude.sh ./cmd/refude-server/openDesktop.sh $GOBIN
 cp -R ./internal/refudeicons/* $HICOLOR_ICON_DIR 
 
 go install ./cmd/refuc
@@ -6,13 +6,10 @@
 package applications
 
 import (
-	"fmt"
-	"os"
+	"errors"
 	"regexp"
-	"strings"
 
 	"github.com/surlykke/refude/internal/lib/entity"
-	"github.com/surlykke/refude/internal/lib/xdg"
 	"github.com/surlykke/refude/pkg/bind"
 )
 
@@ -40,14 +37,16 @@ type DesktopApplication struct {
 	DesktopId       string
 	Mimetypes       []string
 	DesktopFile     string
+	iconName        string // As given in the desktop file, for %i
 }
 
 func (d *DesktopApplication) OmitFromSearch() bool {
 	return d.NoDisplay
 }
 
-func (d *DesktopApplication) Run(arg string) error {
-	return run(d.Exec, arg, d.Terminal)
+// files may be paths or urls
+func (d *DesktopApplication) Run(files ...string) error {
+	return d.launch("", d.Exec, files)
 }
 
 type DesktopAction struct {
@@ -58,20 +57,27 @@ type DesktopAction struct {
 }
 
 func (d *DesktopApplication) DoPost(action string) bind.Response {
+	return d.DoPostWithArguments(action, nil)
+}
+
+// files may be paths or urls. Whether the app is run once with all of them, or once for each, depends on its Exec
+func (d *DesktopApplication) DoPostWithArguments(action string, files []string) bind.Response {
 	if action == "" {
-		return postHelper(d.Exec, d.Terminal)
+		return d.postHelper("", d.Exec, files)
 	} else {
 		for _, dac := range d.DesktopActions {
 			if action == dac.id {
-				return postHelper(dac.Exec, d.Terminal)
+				return d.postHelper(dac.id, dac.Exec, files)
 			}
 		}
 	}
 	return bind.NotFound()
 }
 
-func postHelper(exec string, terminal bool) bind.Response {
-	if err := run(exec, "", terminal); err != nil {
+func (d *DesktopApplication) postHelper(action string, exec string, files []string) bind.Response {
+	if err := d.launch(action, exec, files); errors.Is(err, ErrBadExec) {
+		return bind.UnprocessableEntity(err)
+	} else if err != nil {
 		return bind.ServerError(err)
 	} else {
 		return bind.Accepted()
@@ -79,30 +85,3 @@ func postHelper(exec string, terminal bool) bind.Response {
 }
 
 var argPlaceholders = regexp.MustCompile("%[uUfF]")
-
-func run(exec string, arg string, inTerminal bool) error {
-	var argv = strings.Fields(exec)
-	for i := 0; i < len(argv); i++ {
-		argv[i] = argPlaceholders.ReplaceAllString(argv[i], arg)
-	}
-
-	// Get rid of empty arguments
-	var left = 0
-	for i := 0; i < len(argv); i++ {
-		if len(strings.TrimSpace(argv[i])) > 0 {
-			argv[left] = strings.TrimSpace(argv[i])
-			left++
-		}
-	}
-	argv = argv[0:left]
-
-	if inTerminal {
-		var terminal, ok = os.LookupEnv("TERMINAL")
-		if !ok {
-			return fmt.Errorf("trying to run %s in terminal, but env variable TERMINAL not set", exec)
-		}
-		argv = append([]string{terminal, "-e"}, argv...)
-	}
-
-	return xdg.RunCmd(argv...)
-}
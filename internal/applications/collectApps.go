@@ -61,6 +61,10 @@ func collectApplications(applicationsDir string, apps map[string]*DesktopApplica
 			return nil
 		}
 
+		if app.TryExec != "" && !executableExists(app.TryExec) {
+			return nil
+		}
+
 		if len(app.OnlyShowIn) > 0 && len(xdg.CurrentDesktop) > 0 {
 			var match = false
 			for _, osi := range app.OnlyShowIn {
@@ -170,6 +174,7 @@ func readDesktopFile(filePath string, id string) (*DesktopApplication, error) {
 		var da = DesktopApplication{
 			Base:      *entity.MakeBase(title, group.Entries["Comment"], iconName, "Application", keywords...),
 			DesktopId: id,
+			iconName:  group.Entries["Icon"],
 		}
 
 		da.Comment = group.Entries["Comment"]
@@ -194,6 +199,7 @@ func readDesktopFile(filePath string, id string) (*DesktopApplication, error) {
 		da.Url = group.Entries["URL"]
 		da.Mimetypes = utils.Split(group.Entries["MimeType"], ";")
 		da.DesktopFile = filePath
+		da.Meta.AcceptsArgument = argPlaceholders.MatchString(da.Exec)
 		da.AddAction("", "Open", "")
 		da.DesktopActions = []DesktopAction{}
 		var actionNames = utils.Split(group.Entries["Actions"], ";")
@@ -0,0 +1,204 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package applications
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+/*
+* Exec lines, as described in the desktop entry spec (https://specifications.freedesktop.org/desktop-entry-spec/latest/exec-variables.html)
+*
+* The value is first unescaped as any string value (\s, \n, \t, \r, \\). Then it's split into arguments at (unquoted)
+* spaces. Arguments may be quoted with double quotes, inside which '"', '`', '$' and '\' must be escaped by a backslash.
+*
+* Field codes are then expanded:
+*
+*	%f	a single file, %u a single url. If there are more, the app is launched once for each
+*	%F	all files, %U all urls, as separate arguments
+*	%i	'--icon <Icon>', if the entry has an icon
+*	%c	the (translated) name of the entry
+*	%k	the location of the desktop file
+*	%%	'%'
+*
+* Deprecated field codes (%d, %D, %n, %N, %v, %m) are removed. The spec says field codes should not appear inside
+* quoted arguments, but some entries do so (eg. 'sh -c "foo %f"'), and we expand them there too.
+ */
+
+var ErrBadExec = errors.New("malformed Exec")
+
+func splitExec(execLine string) ([]string, error) {
+	var value = unescapeString(execLine)
+	var args = []string{}
+	var current strings.Builder
+	var inArg, quoted = false, false
+
+	for i := 0; i < len(value); i++ {
+		var c = value[i]
+		if quoted {
+			if c == '"' {
+				quoted = false
+			} else if c == '\\' && i+1 < len(value) && strings.IndexByte("\"`$\\", value[i+1]) > -1 {
+				i++
+				current.WriteByte(value[i])
+			} else {
+				current.WriteByte(c)
+			}
+		} else if c == ' ' || c == '\t' || c == '\n' {
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		} else if c == '"' {
+			quoted, inArg = true, true
+		} else {
+			current.WriteByte(c)
+			inArg = true
+		}
+	}
+
+	if quoted {
+		return nil, fmt.Errorf("%w: unterminated quote in '%s'", ErrBadExec, execLine)
+	} else if inArg {
+		args = append(args, current.String())
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%w: empty", ErrBadExec)
+	}
+	return args, nil
+}
+
+func unescapeString(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			switch value[i+1] {
+			case 's':
+				b.WriteByte(' ')
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case '\\':
+				b.WriteByte('\\')
+			default: // Not ours. Left for the quoting rules
+				b.WriteByte('\\')
+				b.WriteByte(value[i+1])
+			}
+			i++
+		} else {
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
+
+/*
+* Returns the command lines to run for execLine with files (which may be paths or urls). Usually one, but
+* if execLine takes a single file (%f or %u) and several are given, one for each.
+ */
+func (d *DesktopApplication) commandLines(execLine string, files []string) ([][]string, error) {
+	var args, err = splitExec(execLine)
+	if err != nil {
+		return nil, err
+	}
+
+	var takesMany, takesOne = false, false
+	for _, arg := range args {
+		takesMany = takesMany || arg == "%F" || arg == "%U"
+		takesOne = takesOne || strings.Contains(arg, "%f") || strings.Contains(arg, "%u")
+	}
+
+	if !takesMany && takesOne && len(files) > 1 {
+		var result = make([][]string, 0, len(files))
+		for _, file := range files {
+			result = append(result, d.expand(args, []string{file}))
+		}
+		return result, nil
+	} else {
+		return [][]string{d.expand(args, files)}, nil
+	}
+}
+
+func (d *DesktopApplication) expand(args []string, files []string) []string {
+	var result = make([]string, 0, len(args)+len(files))
+	for _, arg := range args {
+		switch arg {
+		case "%F", "%U":
+			result = append(result, files...)
+		case "%i":
+			if d.iconName != "" {
+				result = append(result, "--icon", d.iconName)
+			}
+		case "%f", "%u":
+			if len(files) > 0 {
+				result = append(result, files[0])
+			}
+		default:
+			if expanded := d.expandCodes(arg, files); expanded != "" || arg == "" {
+				result = append(result, expanded)
+			}
+		}
+	}
+	return result
+}
+
+// Expands field codes embedded in an argument, eg. '--file=%f'
+func (d *DesktopApplication) expandCodes(arg string, files []string) string {
+	if !strings.Contains(arg, "%") {
+		return arg
+	}
+	var b strings.Builder
+	for i := 0; i < len(arg); i++ {
+		if arg[i] != '%' || i+1 >= len(arg) {
+			b.WriteByte(arg[i])
+			continue
+		}
+		i++
+		switch arg[i] {
+		case '%':
+			b.WriteByte('%')
+		case 'f', 'u', 'F', 'U': // %F and %U are only meant to stand alone, but we'll do our best
+			if len(files) > 0 {
+				b.WriteString(files[0])
+			}
+		case 'c':
+			b.WriteString(d.Title)
+		case 'k':
+			b.WriteString(d.DesktopFile)
+		case 'i':
+			b.WriteString(d.iconName)
+		case 'd', 'D', 'n', 'N', 'v', 'm':
+			// Deprecated
+		default:
+			b.WriteByte('%')
+			b.WriteByte(arg[i])
+		}
+	}
+	return b.String()
+}
+
+// As per TryExec: A name is looked up in PATH, a path must be to an executable file
+func executableExists(program string) bool {
+	if !strings.Contains(program, "/") {
+		var _, err = exec.LookPath(program)
+		return err == nil
+	} else if info, err := os.Stat(program); err != nil {
+		return false
+	} else {
+		return !info.IsDir() && info.Mode()&0111 != 0
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package applications
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/surlykke/refude/internal/lib/entity"
+)
+
+func TestSplitExec(t *testing.T) {
+	var tests = []struct {
+		exec string
+		args []string
+	}{
+		{`firefox %u`, []string{"firefox", "%u"}},
+		{`  gimp-2.10   %U  `, []string{"gimp-2.10", "%U"}},
+		{`"/opt/My App/bin/app" --flag`, []string{"/opt/My App/bin/app", "--flag"}},
+		{`sh -c "echo \\$HOME"`, []string{"sh", "-c", "echo $HOME"}},
+		{`sh -c "echo \\"quoted\\" \\\\ \\` + "`" + `date\\` + "`" + `"`, []string{"sh", "-c", "echo \"quoted\" \\ `date`"}},
+		{`sh -c "printf 'a\\\\nb'"`, []string{"sh", "-c", `printf 'a\nb'`}},
+		{`app --name=a\sb`, []string{"app", "--name=a", "b"}},
+		{`app ""`, []string{"app", ""}},
+		{`app --opt="x y"z`, []string{"app", "--opt=x yz"}},
+		{`app 100%%`, []string{"app", "100%%"}},
+	}
+	for _, test := range tests {
+		if args, err := splitExec(test.exec); err != nil {
+			t.Errorf("splitExec(%s): %v", test.exec, err)
+		} else if !slices.Equal(args, test.args) {
+			t.Errorf("splitExec(%s): got %q, want %q", test.exec, args, test.args)
+		}
+	}
+}
+
+func TestSplitExecMalformed(t *testing.T) {
+	for _, exec := range []string{``, `   `, `app "unterminated`, `sh -c "echo \\"`} {
+		if args, err := splitExec(exec); !errors.Is(err, ErrBadExec) {
+			t.Errorf("splitExec(%s): got %q, %v, want ErrBadExec", exec, args, err)
+		}
+	}
+}
+
+func TestUnescapeString(t *testing.T) {
+	var tests = []struct {
+		value    string
+		expected string
+	}{
+		{`plain`, `plain`},
+		{`a\sb`, `a b`},
+		{`a\nb\tc\rd`, "a\nb\tc\rd"},
+		{`a\\b`, `a\b`},
+		{`a\$b`, `a\$b`}, // Not a string escape, left for the quoting rules
+		{`trailing\`, `trailing\`},
+	}
+	for _, test := range tests {
+		if unescaped := unescapeString(test.value); unescaped != test.expected {
+			t.Errorf("unescapeString(%s): got %q, want %q", test.value, unescaped, test.expected)
+		}
+	}
+}
+
+func TestCommandLines(t *testing.T) {
+	var app = &DesktopApplication{Base: entity.Base{Title: "Text Editor"}, DesktopFile: "/usr/share/applications/editor.desktop", iconName: "accessories-text-editor"}
+	var tests = []struct {
+		exec  string
+		files []string
+		lines [][]string
+	}{
+		{`editor %F`, []string{"/a", "/b"}, [][]string{{"editor", "/a", "/b"}}},
+		{`editor %F`, nil, [][]string{{"editor"}}},
+		{`editor %f`, []string{"/a", "/b"}, [][]string{{"editor", "/a"}, {"editor", "/b"}}},
+		{`editor %f`, []string{"/a"}, [][]string{{"editor", "/a"}}},
+		{`editor %f`, nil, [][]string{{"editor"}}},
+		{`browser %u`, []string{"https://a", "https://b"}, [][]string{{"browser", "https://a"}, {"browser", "https://b"}}},
+		{`browser %U`, []string{"https://a", "https://b"}, [][]string{{"browser", "https://a", "https://b"}}},
+		{`editor --file=%f`, []string{"/a", "/b"}, [][]string{{"editor", "--file=/a"}, {"editor", "--file=/b"}}},
+		{`sh -c "editor %f"`, []string{"/a"}, [][]string{{"sh", "-c", "editor /a"}}},
+		{`editor %i %F`, []string{"/a"}, [][]string{{"editor", "--icon", "accessories-text-editor", "/a"}}},
+		{`editor --title=%c`, nil, [][]string{{"editor", "--title=Text Editor"}}},
+		{`editor %c`, nil, [][]string{{"editor", "Text Editor"}}},
+		{`editor --desktop-file %k`, nil, [][]string{{"editor", "--desktop-file", "/usr/share/applications/editor.desktop"}}},
+		{`editor --ratio=100%%`, nil, [][]string{{"editor", "--ratio=100%"}}},
+		{`editor %%f`, []string{"/a"}, [][]string{{"editor", "%f"}}},
+		{`editor %d %D %n %N %v %m %F`, []string{"/a"}, [][]string{{"editor", "/a"}}},
+		{`editor --x%d`, nil, [][]string{{"editor", "--x"}}},
+		{`editor %z`, nil, [][]string{{"editor", "%z"}}},
+		{`editor trailing%`, nil, [][]string{{"editor", "trailing%"}}},
+		{`editor ""`, nil, [][]string{{"editor", ""}}},
+	}
+	for _, test := range tests {
+		if lines, err := app.commandLines(test.exec, test.files); err != nil {
+			t.Errorf("commandLines(%s, %q): %v", test.exec, test.files, err)
+		} else if !slices.EqualFunc(lines, test.lines, slices.Equal) {
+			t.Errorf("commandLines(%s, %q): got %q, want %q", test.exec, test.files, lines, test.lines)
+		}
+	}
+}
+
+func TestCommandLinesNoIcon(t *testing.T) {
+	var app = &DesktopApplication{}
+	if lines, err := app.commandLines(`app %i %f`, []string{"/a"}); err != nil {
+		t.Error(err)
+	} else if !slices.EqualFunc(lines, [][]string{{"app", "/a"}}, slices.Equal) {
+		t.Errorf("got %q", lines)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package applications
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/surlykke/refude/internal/lib/xdg"
+)
+
+/*
+* Apps that are DBusActivatable are launched through org.freedesktop.Application on the session bus. Should that fail,
+* or the app not be DBusActivatable, we run Exec, and ask systemd to put the started process in a scope of its own,
+* 'app-refude-<desktop id>-<random>.scope', so it is not part of refude's cgroup.
+*
+* action is the id of a desktop action, or empty for the app itself.
+*
+* ActivateAction takes at most one parameter, so an action given several files is run through its Exec.
+ */
+func (d *DesktopApplication) launch(action string, exec string, files []string) error {
+	if d.DbusActivatable {
+		if err := d.activate(action, files); err == nil {
+			return nil
+		} else if exec == "" {
+			return err
+		} else {
+			log.Print("Could not activate ", d.DesktopId, " over D-Bus, running Exec: ", err)
+		}
+	}
+
+	var commandLines, err = d.commandLines(exec, files)
+	if err != nil {
+		return err
+	}
+
+	var dir = xdg.Home
+	if d.WorkingDir != "" {
+		dir = d.WorkingDir
+	}
+
+	for _, argv := range commandLines {
+		if d.Terminal {
+			var terminal, ok = os.LookupEnv("TERMINAL")
+			if !ok {
+				return fmt.Errorf("trying to run %s in terminal, but env variable TERMINAL not set", exec)
+			}
+			argv = append([]string{terminal, "-e"}, argv...)
+		}
+		if pid, err := xdg.StartCmd(dir, argv...); err != nil {
+			return err
+		} else if err := putInScope(d.DesktopId, pid); err != nil {
+			// The app runs, just not in a scope of its own
+			log.Print("Could not create scope for ", d.DesktopId, ": ", err)
+		}
+	}
+	return nil
+}
+
+const applicationInterface = "org.freedesktop.Application"
+
+// As per the desktop entry spec, the app's well-known name is its desktop id, and its object path derived from that
+func (d *DesktopApplication) activate(action string, files []string) error {
+	var conn, err = dbus.SessionBus()
+	if err != nil {
+		return err
+	}
+	var path = dbus.ObjectPath("/" + strings.ReplaceAll(strings.ReplaceAll(d.DesktopId, ".", "/"), "-", "_"))
+	var obj = conn.Object(d.DesktopId, path)
+	var platformData = map[string]dbus.Variant{}
+
+	var call *dbus.Call
+	if action != "" && len(files) > 1 {
+		return errors.New("an action takes at most one file or url over D-Bus")
+	} else if action != "" {
+		var params = []dbus.Variant{}
+		for _, uri := range toUris(files) {
+			params = append(params, dbus.MakeVariant(uri))
+		}
+		call = obj.Call(applicationInterface+".ActivateAction", dbus.Flags(0), action, params, platformData)
+	} else if len(files) > 0 {
+		call = obj.Call(applicationInterface+".Open", dbus.Flags(0), toUris(files), platformData)
+	} else {
+		call = obj.Call(applicationInterface+".Activate", dbus.Flags(0), platformData)
+	}
+	return call.Err
+}
+
+// Paths become file urls, urls are left as they are
+func toUris(files []string) []string {
+	var uris = make([]string, 0, len(files))
+	for _, file := range files {
+		if u, err := url.Parse(file); err == nil && u.Scheme != "" {
+			uris = append(uris, file)
+		} else if abs, err := filepath.Abs(file); err == nil {
+			uris = append(uris, (&url.URL{Scheme: "file", Path: abs}).String())
+		}
+	}
+	return uris
+}
+
+type unitProperty struct {
+	Name  string
+	Value dbus.Variant
+}
+
+type auxUnit struct {
+	Name       string
+	Properties []unitProperty
+}
+
+func putInScope(desktopId string, pid int) error {
+	var conn, err = dbus.SessionBus()
+	if err != nil {
+		return err
+	}
+	var unitName = fmt.Sprintf("app-refude-%s-%08x.scope", escapeUnitName(desktopId), rand.Uint32())
+	var properties = []unitProperty{
+		{"PIDs", dbus.MakeVariant([]uint32{uint32(pid)})},
+		{"CollectMode", dbus.MakeVariant("inactive-or-failed")},
+	}
+	return conn.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1").
+		Call("org.freedesktop.systemd1.Manager.StartTransientUnit", dbus.Flags(0), unitName, "fail", properties, []auxUnit{}).Err
+}
+
+// Like systemd-escape. Dashes are escaped too, as they separate the parts of the scope name
+func escapeUnitName(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		var c = s[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == ':' || c == '_' || c == '.' && i > 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, `\x%02x`, c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,120 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package applications
+
+import (
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/surlykke/refude/internal/lib/xdg"
+	"github.com/surlykke/refude/pkg/bind"
+)
+
+/*
+* Associations between mimetypes and applications are changed by posting to a mimetype, with one of these actions and
+* the application's desktop id as argument:
+*
+*	default	makes the app the default for the mimetype
+*	add		associates the app with the mimetype
+*	remove	disassociates them
+*
+* Changes are written to $XDG_CONFIG_HOME/mimeapps.list. Lines not changed, including comments, are kept as they are
+* (see xdg.IniDocument). The file being watched, the new associations are picked up by Run.
+ */
+const (
+	defaultAction = "default"
+	addAction     = "add"
+	removeAction  = "remove"
+)
+
+const (
+	defaultApplications = "Default Applications"
+	addedAssociations   = "Added Associations"
+	removedAssociations = "Removed Associations"
+)
+
+var mimeappsListPath = xdg.ConfigHome + "/mimeapps.list"
+var mimeappsListLock sync.Mutex
+
+func (mt *Mimetype) DoPost(action string) bind.Response {
+	return bind.UnprocessableEntity(errors.New("give the application's desktop id as argument"))
+}
+
+func (mt *Mimetype) DoPostWithArguments(action string, args []string) bind.Response {
+	if len(args) != 1 {
+		return bind.UnprocessableEntity(errors.New("give one desktop id as argument"))
+	}
+	var appId = trimAndStripDesktopSuffix(args[0])
+	if _, ok := AppMap.Get(appId); !ok {
+		return bind.UnprocessableEntity(errors.New("no application " + appId))
+	}
+
+	var edit, ok = associationEdit(action, mt.Id, appId)
+	if !ok {
+		return bind.UnprocessableEntity(errors.New("action must be one of 'default', 'add' or 'remove'"))
+	}
+
+	if err := editMimeappsList(edit); err != nil {
+		return bind.ServerError(err)
+	} else {
+		return bind.Accepted()
+	}
+}
+
+// The changes to mimeapps.list that action makes for mimetype and appId
+func associationEdit(action string, mimetype string, appId string) (func(doc *xdg.IniDocument), bool) {
+	switch action {
+	case defaultAction:
+		return func(doc *xdg.IniDocument) {
+			updateAssociations(doc, defaultApplications, mimetype, func(ids []string) []string { return append([]string{appId}, remove(ids, appId)...) })
+			updateAssociations(doc, removedAssociations, mimetype, func(ids []string) []string { return remove(ids, appId) })
+		}, true
+	case addAction:
+		return func(doc *xdg.IniDocument) {
+			updateAssociations(doc, addedAssociations, mimetype, func(ids []string) []string { return appendIfNotThere(ids, appId) })
+			updateAssociations(doc, removedAssociations, mimetype, func(ids []string) []string { return remove(ids, appId) })
+		}, true
+	case removeAction:
+		return func(doc *xdg.IniDocument) {
+			updateAssociations(doc, defaultApplications, mimetype, func(ids []string) []string { return remove(ids, appId) })
+			updateAssociations(doc, addedAssociations, mimetype, func(ids []string) []string { return remove(ids, appId) })
+			updateAssociations(doc, removedAssociations, mimetype, func(ids []string) []string { return appendIfNotThere(ids, appId) })
+		}, true
+	default:
+		return nil, false
+	}
+}
+
+func editMimeappsList(edit func(doc *xdg.IniDocument)) error {
+	mimeappsListLock.Lock()
+	defer mimeappsListLock.Unlock()
+	var doc, err = xdg.ReadIniDocument(mimeappsListPath)
+	if err != nil {
+		return err
+	}
+	edit(doc)
+	return doc.Write(mimeappsListPath)
+}
+
+// Replaces the desktop ids given for mimetype in group with what update returns. If none, the entry is removed.
+func updateAssociations(doc *xdg.IniDocument, group string, mimetype string, update func([]string) []string) {
+	var ids []string
+	for _, id := range doc.GetList(group, mimetype, "") {
+		ids = append(ids, trimAndStripDesktopSuffix(id))
+	}
+
+	if newIds := update(slices.Clone(ids)); slices.Equal(ids, newIds) {
+		return
+	} else if len(newIds) == 0 {
+		doc.Delete(group, mimetype, "")
+	} else {
+		for i := range newIds {
+			newIds[i] = newIds[i] + ".desktop"
+		}
+		doc.SetList(group, mimetype, "", newIds)
+	}
+}
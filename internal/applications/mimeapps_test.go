@@ -0,0 +1,120 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package applications
+
+import (
+	"testing"
+
+	"github.com/surlykke/refude/internal/lib/xdg"
+)
+
+const mimeappsList = `# Edited by hand
+[Default Applications]
+text/plain=org.gnome.gedit.desktop;
+image/png=eog.desktop;gimp.desktop;
+
+[Added Associations]
+# PDFs
+application/pdf=evince.desktop;
+
+[Removed Associations]
+text/plain=vim.desktop;
+`
+
+func TestAssociationEdit(t *testing.T) {
+	var tests = []struct {
+		action   string
+		mimetype string
+		appId    string
+		expected string
+	}{
+		{"default", "text/plain", "vim", `# Edited by hand
+[Default Applications]
+text/plain=vim.desktop;org.gnome.gedit.desktop;
+image/png=eog.desktop;gimp.desktop;
+
+[Added Associations]
+# PDFs
+application/pdf=evince.desktop;
+
+[Removed Associations]
+`},
+		{"default", "image/png", "gimp", `# Edited by hand
+[Default Applications]
+text/plain=org.gnome.gedit.desktop;
+image/png=gimp.desktop;eog.desktop;
+
+[Added Associations]
+# PDFs
+application/pdf=evince.desktop;
+
+[Removed Associations]
+text/plain=vim.desktop;
+`},
+		{"add", "application/pdf", "org.kde.okular", `# Edited by hand
+[Default Applications]
+text/plain=org.gnome.gedit.desktop;
+image/png=eog.desktop;gimp.desktop;
+
+[Added Associations]
+# PDFs
+application/pdf=evince.desktop;org.kde.okular.desktop;
+
+[Removed Associations]
+text/plain=vim.desktop;
+`},
+		{"add", "application/pdf", "evince", mimeappsList},
+		{"remove", "image/png", "gimp", `# Edited by hand
+[Default Applications]
+text/plain=org.gnome.gedit.desktop;
+image/png=eog.desktop;
+
+[Added Associations]
+# PDFs
+application/pdf=evince.desktop;
+
+[Removed Associations]
+text/plain=vim.desktop;
+image/png=gimp.desktop;
+`},
+		{"remove", "application/pdf", "evince", `# Edited by hand
+[Default Applications]
+text/plain=org.gnome.gedit.desktop;
+image/png=eog.desktop;gimp.desktop;
+
+[Added Associations]
+# PDFs
+
+[Removed Associations]
+text/plain=vim.desktop;
+application/pdf=evince.desktop;
+`},
+	}
+	for _, test := range tests {
+		var doc = xdg.ParseIniDocument([]byte(mimeappsList))
+		if edit, ok := associationEdit(test.action, test.mimetype, test.appId); !ok {
+			t.Errorf("%s %s %s: not a known action", test.action, test.mimetype, test.appId)
+		} else if edit(doc); string(doc.Bytes()) != test.expected {
+			t.Errorf("%s %s %s: got\n%s\nwant\n%s", test.action, test.mimetype, test.appId, doc.Bytes(), test.expected)
+		}
+	}
+}
+
+func TestAssociationEditNewGroup(t *testing.T) {
+	var doc = xdg.ParseIniDocument([]byte("[Default Applications]\ntext/plain=gedit.desktop;\n"))
+	var edit, _ = associationEdit("add", "text/html", "firefox")
+	edit(doc)
+	var expected = "[Default Applications]\ntext/plain=gedit.desktop;\n\n[Added Associations]\ntext/html=firefox.desktop;\n"
+	if string(doc.Bytes()) != expected {
+		t.Errorf("got\n%s\nwant\n%s", doc.Bytes(), expected)
+	}
+}
+
+func TestAssociationEditUnknownAction(t *testing.T) {
+	if _, ok := associationEdit("frobnicate", "text/plain", "vim"); ok {
+		t.Error("frobnicate taken as an action")
+	}
+}
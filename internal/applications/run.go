@@ -6,13 +6,16 @@
 package applications
 
 import (
+	"errors"
 	"log"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/surlykke/refude/internal/lib/entity"
 	"github.com/surlykke/refude/internal/lib/xdg"
+	"github.com/surlykke/refude/internal/search"
 	"github.com/surlykke/refude/pkg/pubsub"
 )
 
@@ -21,6 +24,11 @@ var AppEvents = pubsub.MakePublisher[struct{}]()
 var AppMap = entity.MakeMap[string, *DesktopApplication]()
 var MimeMap = entity.MakeMap[string, *Mimetype]()
 
+func init() {
+	search.Register(search.MapProvider("applications", 1, 0, AppMap))
+	search.RegisterLookup(MimeMap)
+}
+
 func Run() {
 	var desktopFileEvents = make(chan struct{})
 	go watchForDesktopFiles(desktopFileEvents)
@@ -65,6 +73,22 @@ func OpenFile(appId, filePath string) bool {
 	return false
 }
 
+// Opens url with the default application for its scheme
+func OpenUrl(u string) error {
+	var parsed, err = url.Parse(u)
+	if err != nil {
+		return err
+	} else if parsed.Scheme == "" {
+		return errors.New("no scheme in " + u)
+	}
+	var mimetype = "x-scheme-handler/" + strings.ToLower(parsed.Scheme)
+	if handlers := GetHandlers(mimetype); len(handlers) == 0 {
+		return errors.New("no application for " + mimetype)
+	} else {
+		return handlers[0].Run(u)
+	}
+}
+
 func watchForDesktopFiles(events chan struct{}) {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -75,7 +99,8 @@ func watchForDesktopFiles(events chan struct{}) {
 	for _, s := range append(xdg.DataDirs, xdg.DataHome) {
 		filesToWatch = append(filesToWatch, s+"/applications")
 	}
-	filesToWatch = append(filesToWatch, xdg.ConfigHome+"/mimeapps.list")
+	// The directory, not the file, as the file may not exist yet, and is replaced when we edit it (see mimeapps.go)
+	filesToWatch = append(filesToWatch, xdg.ConfigHome)
 
 	for _, f := range filesToWatch {
 		if xdg.DirOrFileExists(f) {
@@ -92,7 +117,7 @@ func watchForDesktopFiles(events chan struct{}) {
 		// When the user reinstalls something it will create a number of inotify events. We collect for a couple of seconds
 		// before doing a reload.
 		case event := <-watcher.Events:
-			if !reloadScheduled && strings.HasSuffix(event.Name, ".desktop") || strings.HasSuffix(event.Name, "/mimeapps.list") {
+			if !reloadScheduled && (strings.HasSuffix(event.Name, ".desktop") || strings.HasSuffix(event.Name, "/mimeapps.list")) {
 				reloadScheduled = true
 				go func() {
 					time.Sleep(2 * time.Second)
@@ -0,0 +1,195 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/surlykke/refude/internal/lib/xdg"
+)
+
+/*
+* A random token is made at startup and written to $XDG_RUNTIME_DIR/refude.token, readable only by the user.
+* Requests that change something (ie. not GET, HEAD or OPTIONS) and requests to /watch must carry it,
+* either in the X-Refude-Token header or the refude-token cookie. The token never goes in a url. To give a browser
+* the cookie, a client that has the token POSTs to /desktop/nonce and gets a nonce back, which the browser then
+* exchanges for the cookie by loading /desktop/login?nonce=<nonce> (see 'refuc desktop-url'). A nonce can be
+* used once, and only within a short while, so it is worth nothing once it has ended up in history or logs.
+*
+* Over tcp, the Host header must name the loopback interface, so a page that has had its name rebound to 127.0.0.1
+* gets nowhere. Requests that have an Origin header must come from http://localhost:<port> or
+* http://127.0.0.1:<port>, port being the one the request came in on, or one of the allowed origins.
+ */
+
+const HeaderName = "X-Refude-Token"
+const CookieName = "refude-token"
+
+var token string
+var allowedOrigins []string
+
+func Setup(extraOrigins []string) error {
+	var buf = make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	token = hex.EncodeToString(buf)
+	allowedOrigins = extraOrigins
+
+	os.Remove(xdg.RefudeTokenPath)
+	if file, err := os.OpenFile(xdg.RefudeTokenPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600); err != nil {
+		return err
+	} else {
+		defer file.Close()
+		_, err = file.WriteString(token)
+		return err
+	}
+}
+
+// Reads the token written by a running server
+func ReadToken() (string, error) {
+	if bytes, err := os.ReadFile(xdg.RefudeTokenPath); err != nil {
+		return "", err
+	} else {
+		return string(bytes), nil
+	}
+}
+
+func Guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !hostAllowed(r) {
+			http.Error(w, "Host not allowed", http.StatusForbidden)
+		} else if !originAllowed(r) {
+			http.Error(w, "Origin not allowed", http.StatusForbidden)
+		} else if needsToken(r) && !hasToken(r) {
+			http.Error(w, "Missing or wrong token", http.StatusUnauthorized)
+		} else {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+const nonceLifetime = 30 * time.Second
+
+var nonces = make(map[string]time.Time) // nonce -> expiry
+var noncesLock sync.Mutex
+
+// Hands out a nonce to exchange for the cookie. Being a POST, the request has passed Guard with the token
+var Nonce = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	var buf = make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var nonce = hex.EncodeToString(buf)
+	var now = time.Now()
+
+	noncesLock.Lock()
+	for n, expiry := range nonces {
+		if now.After(expiry) {
+			delete(nonces, n)
+		}
+	}
+	nonces[nonce] = now.Add(nonceLifetime)
+	noncesLock.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write([]byte(nonce))
+})
+
+// Uses up the nonce. True if it was handed out and has not expired
+func consumeNonce(nonce string) bool {
+	noncesLock.Lock()
+	defer noncesLock.Unlock()
+	if expiry, ok := nonces[nonce]; !ok {
+		return false
+	} else {
+		delete(nonces, nonce)
+		return time.Now().Before(expiry)
+	}
+}
+
+// Gives the browser the token as a cookie, in exchange for a nonce, and sends it on to the desktop page
+var Login = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if !consumeNonce(r.URL.Query().Get("nonce")) {
+		http.Error(w, "Missing, used or expired nonce", http.StatusUnauthorized)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, "/desktop/", http.StatusSeeOther)
+})
+
+var loopbackNames = []string{"localhost", "127.0.0.1", "::1"}
+
+// The port the request came in on, and if it came over tcp
+func localPort(r *http.Request) (string, bool) {
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); !ok || addr.Network() != "tcp" {
+		return "", false
+	} else if _, port, err := net.SplitHostPort(addr.String()); err != nil {
+		return "", false
+	} else {
+		return port, true
+	}
+}
+
+func hostAllowed(r *http.Request) bool {
+	if _, tcp := localPort(r); !tcp {
+		return true // Unix socket. Not reachable from a browser
+	}
+	var host = r.Host
+	if h, _, err := net.SplitHostPort(r.Host); err == nil {
+		host = h
+	}
+	return slices.Contains(loopbackNames, host)
+}
+
+func originAllowed(r *http.Request) bool {
+	var origin = r.Header.Get("Origin")
+	if origin == "" || slices.Contains(allowedOrigins, origin) {
+		return true
+	} else if port, tcp := localPort(r); tcp {
+		return origin == "http://localhost:"+port || origin == "http://127.0.0.1:"+port
+	} else {
+		return false
+	}
+}
+
+func needsToken(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return r.URL.Path == "/watch"
+	default:
+		return true
+	}
+}
+
+func hasToken(r *http.Request) bool {
+	var given = r.Header.Get(HeaderName)
+	if given == "" {
+		if cookie, err := r.Cookie(CookieName); err == nil {
+			given = cookie.Value
+		}
+	}
+	return validToken(given)
+}
+
+func validToken(given string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
@@ -6,8 +6,8 @@
 package browser
 
 import (
+	"github.com/surlykke/refude/internal/applications"
 	"github.com/surlykke/refude/internal/lib/entity"
-	"github.com/surlykke/refude/internal/lib/xdg"
 	"github.com/surlykke/refude/pkg/bind"
 )
 
@@ -18,8 +18,11 @@ type Bookmark struct {
 }
 
 func (this *Bookmark) DoPost(action string) bind.Response {
-	xdg.RunCmd("xdg-open", this.ExternalUrl)
-	return bind.Accepted()
+	if err := applications.OpenUrl(this.ExternalUrl); err != nil {
+		return bind.ServerError(err)
+	} else {
+		return bind.Accepted()
+	}
 }
 
 // We use this for icon url
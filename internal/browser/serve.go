@@ -19,6 +19,7 @@ import (
 	"github.com/surlykke/refude/internal/lib/entity"
 	"github.com/surlykke/refude/internal/lib/utils"
 	"github.com/surlykke/refude/internal/lib/xdg"
+	"github.com/surlykke/refude/internal/search"
 	"github.com/surlykke/refude/internal/watch"
 	"github.com/surlykke/refude/pkg/bind"
 	"github.com/surlykke/refude/pkg/pubsub"
@@ -27,6 +28,11 @@ import (
 var TabMap = entity.MakeMap[string, *Tab]()
 var BookmarkMap = entity.MakeMap[string, *Bookmark]()
 
+func init() {
+	search.Register(search.MapProvider("tabs", 0, 0, TabMap))
+	search.Register(search.MapProvider("bookmarks", 3, 0, BookmarkMap))
+}
+
 // Data sent to the browser
 type browserCommand struct {
 	BrowserId string `json:"browserId"`
@@ -6,6 +6,8 @@
 package browser
 
 import (
+	"net"
+	"net/url"
 	"strings"
 
 	"github.com/surlykke/refude/internal/lib/entity"
@@ -24,6 +26,14 @@ func (this *Tab) DoPost(action string) bind.Response {
 	return bind.Accepted()
 }
 
+// Our own desktop page, wherever refude-server listens
 func (this *Tab) OmitFromSearch() bool {
-	return strings.HasPrefix(this.Url, "http://localhost:7938/desktop")
+	if u, err := url.Parse(this.Url); err != nil || !strings.HasPrefix(u.Path, "/desktop") {
+		return false
+	} else if u.Hostname() == "localhost" {
+		return true
+	} else {
+		var ip = net.ParseIP(u.Hostname())
+		return ip != nil && ip.IsLoopback()
+	}
 }
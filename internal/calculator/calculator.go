@@ -0,0 +1,111 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package calculator
+
+import (
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/surlykke/refude/internal/lib/entity"
+	"github.com/surlykke/refude/internal/lib/xdg"
+	"github.com/surlykke/refude/internal/search"
+	"github.com/surlykke/refude/pkg/bind"
+)
+
+/*
+* Answers searches like '=2^10*3', 'sqrt(2)/2' or '10 km in miles'. Posting to the result copies it
+* to the clipboard.
+*
+* Searches starting with '=' are always taken as expressions. Otherwise the term must contain at least one
+* operation, so a plain number isn't answered.
+*
+* A calculation's id is the search term, and it is calculated again when asked for, so searching doesn't
+* change anything.
+ */
+
+type Calculation struct {
+	entity.Base
+	Expression string
+	Value      string
+}
+
+// Holds nothing. Calculations are served by the fallback
+var CalculationMap = entity.MakeMap[string, *Calculation]()
+
+func init() {
+	CalculationMap.SetFallback(calculate)
+	search.Register(calculatorProvider{})
+}
+
+// Copying a result is not worth remembering for search ranking
+func (this *Calculation) Unrecorded() {}
+
+func (this *Calculation) DoPost(action string) bind.Response {
+	if action != "" {
+		return bind.NotFound()
+	} else if err := xdg.RunCmd("wl-copy", "--", this.Value); err != nil {
+		return bind.ServerError(err)
+	} else {
+		return bind.Accepted()
+	}
+}
+
+type calculatorProvider struct{}
+
+func (calculatorProvider) Name() string       { return "calculator" }
+func (calculatorProvider) MinTermLength() int { return 1 }
+func (calculatorProvider) Weight() uint       { return 0 }
+func (calculatorProvider) Answers() bool      { return true }
+
+func (calculatorProvider) Candidates(term string) []entity.Base {
+	if calculation, ok := calculate(term); ok {
+		calculation.Meta.Path = CalculationMap.GetPrefix() + url.PathEscape(strings.TrimSpace(term))
+		return []entity.Base{calculation.Base}
+	}
+	return nil
+}
+
+func calculate(term string) (*Calculation, bool) {
+	var expression = strings.TrimSpace(term)
+	var value float64
+	var title string
+	if converted, toUnit, ok, err := convert(expression); ok {
+		if err != nil {
+			return nil, false
+		}
+		value, title = converted, format(converted)+" "+toUnit
+	} else {
+		var forced = strings.HasPrefix(expression, "=")
+		expression = strings.TrimSpace(strings.TrimPrefix(expression, "="))
+		var operations int
+		if value, operations, err = evaluate(expression); err != nil || (operations == 0 && !forced) {
+			return nil, false
+		}
+		title = format(value)
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil, false
+	}
+
+	var calculation = &Calculation{
+		Base:       *entity.MakeBase(title, expression, "accessories-calculator", "Calculation"),
+		Expression: expression,
+		Value:      format(value),
+	}
+	calculation.AddAction("", "Copy", "edit-copy")
+	return calculation, true
+}
+
+// Integers as such, other values with up to 12 significant digits
+func format(value float64) string {
+	if value == math.Trunc(value) && math.Abs(value) < 1e15 {
+		return strconv.FormatFloat(value, 'f', 0, 64)
+	} else {
+		return strconv.FormatFloat(value, 'g', 12, 64)
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package calculator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"unicode"
+)
+
+/*
+* A recursive descent parser/evaluator for arithmetic:
+*
+*   expr    = term { ('+' | '-') term }
+*   term    = unary { ('*' | '/' | '%') unary }
+*   unary   = ('-' | '+') unary | power
+*   power   = primary [ ('^' | '**') unary ]
+*   primary = number | '(' expr ')' | constant | function '(' expr ')'
+*
+* So '-2^2' is -4 and '2^3^2' is 512.
+ */
+
+var constants = map[string]float64{
+	"pi": math.Pi,
+	"π":  math.Pi,
+	"e":  math.E,
+}
+
+var functions = map[string]func(float64) float64{
+	"sqrt":  math.Sqrt,
+	"abs":   math.Abs,
+	"exp":   math.Exp,
+	"ln":    math.Log,
+	"log":   math.Log10,
+	"log2":  math.Log2,
+	"sin":   math.Sin,
+	"cos":   math.Cos,
+	"tan":   math.Tan,
+	"asin":  math.Asin,
+	"acos":  math.Acos,
+	"atan":  math.Atan,
+	"floor": math.Floor,
+	"ceil":  math.Ceil,
+	"round": math.Round,
+}
+
+type parser struct {
+	input      []rune
+	pos        int
+	operations int // Binary operators and function calls seen
+}
+
+// Evaluates expression, also returning the number of operations in it, so callers can tell '2+2' from '4'
+func evaluate(expression string) (float64, int, error) {
+	var p = parser{input: []rune(expression)}
+	var val, err = p.expr()
+	if err == nil && p.skipSpace() < len(p.input) {
+		err = fmt.Errorf("unexpected '%c'", p.input[p.pos])
+	}
+	return val, p.operations, err
+}
+
+func (p *parser) skipSpace() int {
+	for p.pos < len(p.input) && unicode.IsSpace(p.input[p.pos]) {
+		p.pos++
+	}
+	return p.pos
+}
+
+// Consumes one of the operators, if next in input, and returns it
+func (p *parser) operator(operators ...string) string {
+	p.skipSpace()
+	for _, op := range operators {
+		var opRunes = []rune(op)
+		if p.pos+len(opRunes) <= len(p.input) && string(p.input[p.pos:p.pos+len(opRunes)]) == op {
+			p.pos += len(opRunes)
+			return op
+		}
+	}
+	return ""
+}
+
+func (p *parser) expr() (float64, error) {
+	var val, err = p.term()
+	for err == nil {
+		var rhs float64
+		switch p.operator("+", "-") {
+		case "+":
+			rhs, err = p.term()
+			val += rhs
+		case "-":
+			rhs, err = p.term()
+			val -= rhs
+		default:
+			return val, nil
+		}
+		p.operations++
+	}
+	return val, err
+}
+
+func (p *parser) term() (float64, error) {
+	var val, err = p.unary()
+	for err == nil {
+		// '**' is power, not two multiplications
+		if p.skipSpace()+1 < len(p.input) && p.input[p.pos] == '*' && p.input[p.pos+1] == '*' {
+			return val, nil
+		}
+		var rhs float64
+		switch p.operator("*", "×", "/", "÷", "%") {
+		case "*", "×":
+			rhs, err = p.unary()
+			val *= rhs
+		case "/", "÷":
+			rhs, err = p.unary()
+			val /= rhs
+		case "%":
+			rhs, err = p.unary()
+			val = math.Mod(val, rhs)
+		default:
+			return val, nil
+		}
+		p.operations++
+	}
+	return val, err
+}
+
+func (p *parser) unary() (float64, error) {
+	switch p.operator("-", "+") {
+	case "-":
+		var val, err = p.unary()
+		return -val, err
+	case "+":
+		return p.unary()
+	default:
+		return p.power()
+	}
+}
+
+func (p *parser) power() (float64, error) {
+	var val, err = p.primary()
+	if err != nil {
+		return 0, err
+	}
+	if p.operator("^", "**") != "" {
+		var exponent float64
+		if exponent, err = p.unary(); err != nil {
+			return 0, err
+		}
+		p.operations++
+		val = math.Pow(val, exponent)
+	}
+	return val, nil
+}
+
+func (p *parser) primary() (float64, error) {
+	if p.skipSpace() >= len(p.input) {
+		return 0, errors.New("unexpected end of expression")
+	}
+	var r = p.input[p.pos]
+	if r == '(' {
+		p.pos++
+		var val, err = p.expr()
+		if err != nil {
+			return 0, err
+		} else if p.operator(")") == "" {
+			return 0, errors.New("missing ')'")
+		}
+		return val, nil
+	} else if unicode.IsDigit(r) || r == '.' {
+		return p.number()
+	} else if unicode.IsLetter(r) {
+		var start = p.pos
+		for p.pos < len(p.input) && (unicode.IsLetter(p.input[p.pos]) || unicode.IsDigit(p.input[p.pos])) {
+			p.pos++
+		}
+		var name = string(p.input[start:p.pos])
+		if c, ok := constants[name]; ok {
+			return c, nil
+		} else if f, ok := functions[name]; !ok {
+			return 0, fmt.Errorf("unknown: %s", name)
+		} else if p.operator("(") == "" {
+			return 0, fmt.Errorf("missing '(' after %s", name)
+		} else if arg, err := p.expr(); err != nil {
+			return 0, err
+		} else if p.operator(")") == "" {
+			return 0, errors.New("missing ')'")
+		} else {
+			p.operations++
+			return f(arg), nil
+		}
+	} else {
+		return 0, fmt.Errorf("unexpected '%c'", r)
+	}
+}
+
+func (p *parser) number() (float64, error) {
+	var start = p.pos
+	var digits = func() {
+		for p.pos < len(p.input) && unicode.IsDigit(p.input[p.pos]) {
+			p.pos++
+		}
+	}
+	digits()
+	if p.pos < len(p.input) && p.input[p.pos] == '.' {
+		p.pos++
+		digits()
+	}
+	// An exponent, but only if digits follow - 'e' alone could be the constant
+	if p.pos+1 < len(p.input) && (p.input[p.pos] == 'e' || p.input[p.pos] == 'E') {
+		var next = p.pos + 1
+		if next+1 < len(p.input) && (p.input[next] == '+' || p.input[next] == '-') {
+			next++
+		}
+		if next < len(p.input) && unicode.IsDigit(p.input[next]) {
+			p.pos = next
+			digits()
+		}
+	}
+	return strconv.ParseFloat(string(p.input[start:p.pos]), 64)
+}
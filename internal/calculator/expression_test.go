@@ -0,0 +1,81 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package calculator
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	var tests = []struct {
+		expression string
+		value      float64
+		operations int
+	}{
+		{"4", 4, 0},
+		{"  2.5 ", 2.5, 0},
+		{".5", 0.5, 0},
+		{"1e3", 1000, 0},
+		{"1.5E-2", 0.015, 0},
+		{"2+3*4", 14, 2},
+		{"(2+3)*4", 20, 2},
+		{"2*(3+4)*5", 70, 3},
+		{"((1))", 1, 0},
+		{"10-4-3", 3, 2}, // Left associative
+		{"64/4/2", 8, 2},
+		{"7 % 3", 1, 1},
+		{"6 × 7 ÷ 2", 21, 2},
+		{"2^10", 1024, 1},
+		{"2**10", 1024, 1},
+		{"2^3^2", 512, 2}, // Right associative
+		{"2*3^2", 18, 2},
+		{"-2", -2, 0},
+		{"--2", 2, 0},
+		{"+2", 2, 0},
+		{"-2^2", -4, 1},
+		{"2^-1", 0.5, 1},
+		{"3--2", 5, 1},
+		{"-(1+2)", -3, 1},
+		{"pi", math.Pi, 0},
+		{"2*π", 2 * math.Pi, 1},
+		{"e", math.E, 0},
+		{"sqrt(16)", 4, 1},
+		{"sqrt(9)+abs(-1)", 4, 3},
+		{"log(1000)", 3, 1},
+		{"round(2.5)", 3, 1},
+	}
+	for _, test := range tests {
+		if value, operations, err := evaluate(test.expression); err != nil {
+			t.Errorf("evaluate(%s): %v", test.expression, err)
+		} else if math.Abs(value-test.value) > 1e-12 || operations != test.operations {
+			t.Errorf("evaluate(%s): got %v, %d, want %v, %d", test.expression, value, operations, test.value, test.operations)
+		}
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	for _, expression := range []string{
+		"",
+		"   ",
+		"2+",
+		"*2",
+		"(1+2",
+		"1+2)",
+		"sqrt 4",
+		"sqrt(4",
+		"foo(1)",
+		"x",
+		"2e", // Not an exponent, and then the 'e' is unexpected
+		"1 2",
+		"2 > 1",
+		"1..2",
+	} {
+		if value, _, err := evaluate(expression); err == nil {
+			t.Errorf("evaluate(%s): got %v, want an error", expression, value)
+		}
+	}
+}
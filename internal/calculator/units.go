@@ -0,0 +1,108 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package calculator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// A value in a unit is value*factor + offset in the base unit of its dimension
+type unit struct {
+	dimension string
+	factor    float64
+	offset    float64
+}
+
+var units = map[string]unit{}
+
+func addUnit(dimension string, factor float64, offset float64, names ...string) {
+	for _, name := range names {
+		units[name] = unit{dimension, factor, offset}
+	}
+}
+
+func init() {
+	addUnit("length", 1, 0, "m", "meter", "meters", "metre", "metres")
+	addUnit("length", 1000, 0, "km", "kilometer", "kilometers", "kilometre", "kilometres")
+	addUnit("length", 0.01, 0, "cm", "centimeter", "centimeters")
+	addUnit("length", 0.001, 0, "mm", "millimeter", "millimeters")
+	addUnit("length", 1609.344, 0, "mi", "mile", "miles")
+	addUnit("length", 0.9144, 0, "yd", "yard", "yards")
+	addUnit("length", 0.3048, 0, "ft", "foot", "feet")
+	addUnit("length", 0.0254, 0, "in", "inch", "inches")
+	addUnit("length", 1852, 0, "nmi")
+
+	addUnit("mass", 1, 0, "kg", "kilogram", "kilograms", "kilo", "kilos")
+	addUnit("mass", 0.001, 0, "g", "gram", "grams")
+	addUnit("mass", 0.000001, 0, "mg", "milligram", "milligrams")
+	addUnit("mass", 1000, 0, "t", "tonne", "tonnes")
+	addUnit("mass", 0.45359237, 0, "lb", "lbs", "pound", "pounds")
+	addUnit("mass", 0.028349523125, 0, "oz", "ounce", "ounces")
+	addUnit("mass", 6.35029318, 0, "st", "stone", "stones")
+
+	addUnit("time", 1, 0, "s", "sec", "second", "seconds")
+	addUnit("time", 0.001, 0, "ms", "millisecond", "milliseconds")
+	addUnit("time", 60, 0, "min", "minute", "minutes")
+	addUnit("time", 3600, 0, "h", "hour", "hours")
+	addUnit("time", 86400, 0, "d", "day", "days")
+	addUnit("time", 604800, 0, "week", "weeks")
+	addUnit("time", 31557600, 0, "year", "years") // Julian
+
+	addUnit("volume", 1, 0, "l", "liter", "liters", "litre", "litres")
+	addUnit("volume", 0.1, 0, "dl")
+	addUnit("volume", 0.01, 0, "cl")
+	addUnit("volume", 0.001, 0, "ml")
+	addUnit("volume", 1000, 0, "m3")
+	addUnit("volume", 3.785411784, 0, "gal", "gallon", "gallons") // US
+	addUnit("volume", 0.946352946, 0, "qt", "quart", "quarts")
+	addUnit("volume", 0.473176473, 0, "pt", "pint", "pints")
+	addUnit("volume", 0.2365882365, 0, "cup", "cups")
+	addUnit("volume", 0.0295735295625, 0, "floz")
+
+	addUnit("temperature", 1, 0, "k", "kelvin")
+	addUnit("temperature", 1, 273.15, "c", "°c", "celsius")
+	addUnit("temperature", 5.0/9, 273.15-32*5.0/9, "f", "°f", "fahrenheit")
+
+	addUnit("speed", 1, 0, "m/s")
+	addUnit("speed", 1/3.6, 0, "km/h", "kmh", "kph")
+	addUnit("speed", 0.44704, 0, "mph")
+	addUnit("speed", 1852/3600.0, 0, "kn", "knot", "knots")
+
+	addUnit("data", 1, 0, "b", "byte", "bytes")
+	addUnit("data", 1e3, 0, "kb")
+	addUnit("data", 1e6, 0, "mb")
+	addUnit("data", 1e9, 0, "gb")
+	addUnit("data", 1e12, 0, "tb")
+	addUnit("data", 1<<10, 0, "kib")
+	addUnit("data", 1<<20, 0, "mib")
+	addUnit("data", 1<<30, 0, "gib")
+	addUnit("data", 1<<40, 0, "tib")
+}
+
+// Eg. '10 km in miles', '3*12 ft to m' or '20c as f'
+var conversion = regexp.MustCompile(`^(.+?)\s*([^\s\d().+\-*/^%][^\s]*)\s+(?:in|to|as)\s+(\S+)$`)
+
+// Returns value converted and the unit converted to (as given), if term is a conversion
+func convert(term string) (float64, string, bool, error) {
+	var m = conversion.FindStringSubmatch(strings.TrimSpace(term))
+	if m == nil {
+		return 0, "", false, nil
+	}
+	var from, fromOk = units[strings.ToLower(m[2])]
+	var to, toOk = units[strings.ToLower(m[3])]
+	if !fromOk || !toOk {
+		return 0, "", false, nil
+	} else if from.dimension != to.dimension {
+		return 0, "", true, fmt.Errorf("can't convert %s to %s", from.dimension, to.dimension)
+	}
+	var amount, _, err = evaluate(m[1])
+	if err != nil {
+		return 0, "", true, err
+	}
+	return ((amount*from.factor + from.offset) - to.offset) / to.factor, m[3], true, nil
+}
@@ -0,0 +1,82 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package calculator
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	var tests = []struct {
+		term   string
+		value  float64
+		toUnit string
+	}{
+		{"10 km in miles", 6.213711922373339, "miles"},
+		{"1 mi to m", 1609.344, "m"},
+		{"3*12 ft to m", 10.9728, "m"},
+		{"(1+1) kg as lb", 4.409245243697551, "lb"},
+		{"100c in f", 212, "f"},
+		{"32 °F to °C", 0, "°C"},
+		{"0 c in k", 273.15, "k"},
+		{"1 KiB in b", 1024, "b"},
+		{"1 gb in mb", 1000, "mb"},
+		{"90 km/h in m/s", 25, "m/s"},
+		{"2 hours in min", 120, "min"},
+		{"  1 l in ml  ", 1000, "ml"},
+	}
+	for _, test := range tests {
+		if value, toUnit, ok, err := convert(test.term); !ok || err != nil {
+			t.Errorf("convert(%s): got %t, %v", test.term, ok, err)
+		} else if math.Abs(value-test.value) > 1e-9 || toUnit != test.toUnit {
+			t.Errorf("convert(%s): got %v %s, want %v %s", test.term, value, toUnit, test.value, test.toUnit)
+		}
+	}
+}
+
+func TestConvertFails(t *testing.T) {
+	var tests = []struct {
+		term         string
+		isConversion bool // A conversion that fails, rather than not a conversion
+	}{
+		{"10 km in kg", true},
+		{"1 c in m", true},
+		{"x km in m", true},
+		{"2+2", false},
+		{"10 parsecs in km", false},
+		{"10 km in furlongs", false},
+		{"km in m", true}, // Taken as k m, k not being a number
+	}
+	for _, test := range tests {
+		if _, _, ok, err := convert(test.term); ok != test.isConversion || err == nil && ok {
+			t.Errorf("convert(%s): got %t, %v", test.term, ok, err)
+		}
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	var tests = []struct {
+		term  string
+		ok    bool
+		value string
+	}{
+		{"2+2", true, "4"},
+		{"=4", true, "4"},
+		{"4", false, ""}, // No operation
+		{"sqrt(2)", true, "1.41421356237"},
+		{"1/0", false, ""},
+		{"10 km in m", true, "10000"},
+		{"firefox", false, ""},
+	}
+	for _, test := range tests {
+		if calculation, ok := calculate(test.term); ok != test.ok {
+			t.Errorf("calculate(%s): got %t", test.term, ok)
+		} else if ok && calculation.Value != test.value {
+			t.Errorf("calculate(%s): got %s, want %s", test.term, calculation.Value, test.value)
+		}
+	}
+}
@@ -5,12 +5,29 @@
 // Please refer to the GPL2 file for a copy of the license.
 //
 let term = ""
+let dirs = [] // Directories browsed into, innermost last. Each with resource path and title
 
 let setTerm = newTerm => {
 	document.getElementById("term").textContent = term = newTerm
 	document.getElementById("search-results").dispatchEvent(new Event("search"))
 }
 
+let currentDir = () => dirs.at(-1)?.path ?? ""
+
+let setDirs = newDirs => {
+	dirs = newDirs
+	document.getElementById("dir").textContent = dirs.map(d => d.title + " ›").join(" ")
+	setTerm("")
+}
+
+let doBrowse = () => {
+	let element = document.activeElement
+	if (element?.dataset.browse) {
+		setDirs([...dirs, { path: element.dataset.browse, title: element.textContent.replace("›", "").trim() }])
+		return true
+	}
+}
+
 let doCtrlSpace = () => {
 	document.activeElement?.dispatchEvent(new Event("details"))
 }
@@ -22,6 +39,8 @@ let doEscape = shiftKey => {
 		setTerm(term)
 	} else if (term) {
 		setTerm("")
+	} else if (dirs.length > 0) {
+		setDirs(dirs.slice(0, -1))
 	} else {
 		dismiss()
 	}
@@ -33,11 +52,18 @@ let doEnter = (ctrl, shift) => {
 	} else {
 		href = document.activeElement?.dataset.href
 		if (href) {
-			fetch(href, { method: "post" }).then(resp => resp.ok && !shift && dismiss())
+			fetch(href, { method: "post", headers: { "X-Refude-Term": encodeURIComponent(term) } }).then(resp => resp.ok && !shift && dismiss())
 		}
 	}
 }
 
+let doDelete = shift => {
+	deleteHref = document.activeElement?.dataset.deleteHref
+	if (deleteHref) {
+		fetch(deleteHref, { method: "delete" }).then(resp => resp.ok && (shift ? dismiss() : setTerm(term)))
+	}
+}
+
 let setTabIndexes = () => {
 	document.querySelectorAll('[data-href]').forEach((e, i) => e.tabIndex = i + 1)
 	document.activeElement?.hasAttribute('tabindex') || document.querySelector('[tabIndex="1"]')?.focus()
@@ -58,7 +84,7 @@ let onKeyDown = event => {
 	} else if (key === "Delete" && !altKey && ctrlKey) {
 		doDelete(shiftKey)
 	} else if (key === "Backspace" && !ctrlKey && !altKey && !shiftKey) {
-		setTerm(term.slice(0, -1))
+		term || dirs.length === 0 ? setTerm(term.slice(0, -1)) : setDirs(dirs.slice(0, -1))
 	} else if (key.length === 1 && !ctrlKey && !altKey) {
 		setTerm(term + key)
 	} else if (key === "ArrowDown" || (ctrlKey && key === 'j')) {
@@ -66,7 +92,7 @@ let onKeyDown = event => {
 	} else if (key === "ArrowUp" || (ctrlKey && key === 'k')) {
 		nextLink('up')?.focus()
 	} else if (key === "ArrowRight" || (ctrlKey && key === 'l')) {
-		document.activeElement?.nextElementSibling?.focus()
+		doBrowse() || document.activeElement?.nextElementSibling?.focus()
 	}  else if (key === "ArrowLeft" || (ctrlKey && key === 'h')) {
 		document.activeElement?.previousElementSibling?.focus()
 	} else {
@@ -12,6 +12,8 @@ import (
 	"io/fs"
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/surlykke/refude/internal/lib/entity"
 	"github.com/surlykke/refude/internal/search"
@@ -23,6 +25,7 @@ var sources embed.FS
 
 var rowTemplate *template.Template
 var detailsTemplate *template.Template
+var resourceTemplate *template.Template
 
 var StaticServer http.Handler
 
@@ -38,6 +41,7 @@ func loadTemplate(name, relPath string) *template.Template {
 func init() {
 	rowTemplate = loadTemplate("rowTemplate", "html/rowTemplate.html")
 	detailsTemplate = loadTemplate("detailsTemplate", "html/detailsTemplate.html")
+	resourceTemplate = loadTemplate("resourceTemplate", "html/resourceTemplate.html")
 
 	var tmp http.Handler
 
@@ -47,32 +51,54 @@ func init() {
 		log.Panic(err)
 	}
 	StaticServer = http.StripPrefix("/desktop", tmp)
-
 }
 
 type Resourceline struct {
 	Icon        string
-	Title       string
+	Title       []Segment
+	Keyword     []Segment
 	Comment     string
 	Href        string
 	Path        string
+	DeleteHref  string
 	MoreActions bool
+	Browsable   bool
 }
 
-func SearchHandler(term string) bind.Response {
+// With dir (a resource path) given, the search is among its children
+func SearchHandler(term string, dir string) bind.Response {
 	var (
-		lines []Resourceline
+		lines  []Resourceline
+		query  = search.ParseQuery(term)
+		result []search.Ranked
 	)
 
-	for _, r := range search.Search(term) {
+	if dir == "" {
+		result = search.Search(term)
+	} else {
+		result, _ = search.Browse(dir, term) // If dir has gone, we show nothing
+	}
+
+	for _, r := range result {
 
-		var line = Resourceline{Icon: string(r.Icon), Title: r.Title, Comment: r.Subtitle}
-		var links = r.Links(entity.OrgRefudeAction)
+		var line = Resourceline{Icon: string(r.Icon), Title: segments(r.Title, r.TitleRanges), Comment: r.Subtitle}
+		if r.Keyword != "" {
+			line.Keyword = segments(r.Keyword, r.KeywordRanges)
+		}
+		// The self link first, posting to which runs the default action
+		var links = r.Links(entity.Self, entity.OrgRefudeAction)
 		if len(links) > 0 {
 			line.Href = links[0].Href
+			if query.HasArgument && query.Argument != "" && r.Meta.AcceptsArgument { // Answers, like calculations, take none
+				line.Href = withArgument(line.Href, query.Argument)
+			}
 			line.Path = r.Meta.Path
 		}
 		line.MoreActions = len(links) > 1
+		line.Browsable = r.Meta.Browsable
+		if deleteLinks := r.Links(entity.OrgRefudeDelete); len(deleteLinks) > 0 {
+			line.DeleteHref = deleteLinks[0].Href
+		}
 		lines = append(lines, line)
 	}
 
@@ -85,6 +111,40 @@ func SearchHandler(term string) bind.Response {
 	}
 }
 
+func withArgument(href string, arg string) string {
+	if strings.Contains(href, "?") {
+		return href + "&arg=" + url.QueryEscape(arg)
+	} else {
+		return href + "?arg=" + url.QueryEscape(arg)
+	}
+}
+
+// A piece of text, matching the search term or not
+type Segment struct {
+	Text    string
+	Matched bool
+}
+
+func segments(text string, ranges []search.Range) []Segment {
+	var runes = []rune(text)
+	var result = make([]Segment, 0, 2*len(ranges)+1)
+	var pos = 0
+	for _, r := range ranges {
+		if r.Start < pos || r.End > len(runes) {
+			break // Shouldn't happen
+		}
+		if r.Start > pos {
+			result = append(result, Segment{Text: string(runes[pos:r.Start])})
+		}
+		result = append(result, Segment{Text: string(runes[r.Start:r.End]), Matched: true})
+		pos = r.End
+	}
+	if pos < len(runes) {
+		result = append(result, Segment{Text: string(runes[pos:])})
+	}
+	return result
+}
+
 type Detail struct {
 	Name string
 	Href string
@@ -94,10 +154,21 @@ func DetailsHandler(resPath string) bind.Response {
 	var b bytes.Buffer
 	if base, ok := search.SearchByPath(resPath); !ok {
 		return bind.NotFound()
-	} else if err := detailsTemplate.Execute(&b, base.Links()); err != nil {
+	} else if err := detailsTemplate.Execute(&b, base.Links(entity.Self, entity.OrgRefudeAction)); err != nil {
 		log.Print(err)
 		return bind.ServerError(err)
 	} else {
 		return bind.Html(b.Bytes())
 	}
 }
+
+// Renders a resource, data being name/value pairs
+func RenderResource(title string, icon string, data [][]string) ([]byte, error) {
+	var b bytes.Buffer
+	var err = resourceTemplate.Execute(&b, struct {
+		Title string
+		Icon  string
+		Data  [][]string
+	}{title, icon, data})
+	return b.Bytes(), err
+}
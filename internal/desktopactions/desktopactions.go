@@ -12,6 +12,7 @@ import (
 
 	"github.com/godbus/dbus/v5"
 	"github.com/surlykke/refude/internal/lib/entity"
+	"github.com/surlykke/refude/internal/search"
 	"github.com/surlykke/refude/pkg/bind"
 )
 
@@ -54,4 +55,5 @@ func init() {
 		res.AddAction("", "", "")
 		PowerActions.Put(data[0], &res)
 	}
+	search.Register(search.MapProvider("start", 3, 0, PowerActions))
 }
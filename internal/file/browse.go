@@ -0,0 +1,150 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package file
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/surlykke/refude/internal/lib/entity"
+	"github.com/surlykke/refude/internal/search"
+)
+
+/*
+* Directories may be browsed, also below the depth indexed: Any path under one of the configured roots, that
+* the indexer would not leave out for being hidden or ignored, can be served from FileMap (see fallback).
+* GET on a directory lists its entries as 'related' links, directories first, then by name, leaving out those
+* the indexer would. The listing is paginated with query parameters 'offset' and 'limit' (a 'next' link is given
+* when there is more). A limit above maxLimit is taken as maxLimit.
+ */
+
+const defaultLimit = 100
+const maxLimit = 1000
+
+// The configuration last read by the indexer
+var browseConfig atomic.Pointer[config]
+
+func init() {
+	FileMap.SetFallback(fallback)
+}
+
+func fallback(k string) (*File, bool) {
+	var osPath = "/" + k
+	if c := browseConfig.Load(); c == nil || filepath.Clean(osPath) != osPath {
+		return nil, false
+	} else if info, err := os.Lstat(osPath); err != nil {
+		return nil, false
+	} else if _, ok := c.admits(osPath, info.IsDir()); !ok {
+		return nil, false
+	} else {
+		var f, err = makeFileFromPath(osPath)
+		return f, err == nil && f != nil
+	}
+}
+
+func (f *File) Expand(params url.Values) (any, error) {
+	if f.Type != "Directory" {
+		return f, nil
+	}
+	var offset, limit = 0, defaultLimit
+	var err error
+	if params.Has("offset") {
+		if offset, err = strconv.Atoi(params.Get("offset")); err != nil || offset < 0 {
+			return nil, errors.New("offset must be a non-negative integer")
+		}
+	}
+	if params.Has("limit") {
+		if limit, err = strconv.Atoi(params.Get("limit")); err != nil || limit < 1 {
+			return nil, errors.New("limit must be a positive integer")
+		}
+		limit = min(limit, maxLimit)
+	}
+
+	var entries = browsableEntries(f.OsPath)
+	var start = min(offset, len(entries))
+	var end = start + min(limit, len(entries)-start) // Not offset+limit, which may overflow
+	var expanded = *f
+	expanded.Meta.Extra = make([]entity.Link, 0, end-start+2)
+	if parent := filepath.Dir(f.OsPath); parent != "/" { // The root directory has no resource path of its own
+		expanded.Meta.Extra = append(expanded.Meta.Extra, entity.Link{Href: FileMap.GetPrefix() + key(parent), Relation: entity.Parent})
+	}
+	for _, entry := range entries[start:end] {
+		if child, ok := entryFile(f.OsPath, entry); ok {
+			expanded.Meta.Extra = append(expanded.Meta.Extra, entity.Link{Href: child.Meta.Path, Title: child.Name, Icon: child.Icon, Relation: entity.Related})
+		}
+	}
+	if end < len(entries) {
+		var next = fmt.Sprintf("%s?offset=%d&limit=%d", f.Meta.Path, end, limit)
+		expanded.Meta.Extra = append(expanded.Meta.Extra, entity.Link{Href: next, Relation: entity.Next})
+	}
+	return &expanded, nil
+}
+
+// The entries of dir the indexer would not leave out, directories first, then by name
+func browsableEntries(dir string) []os.DirEntry {
+	var c = browseConfig.Load()
+	if c == nil {
+		return nil
+	}
+	var ign, ok = c.admits(dir, true)
+	if !ok {
+		return nil
+	}
+	var entries = slices.DeleteFunc(readEntries(dir), func(e os.DirEntry) bool {
+		return c.excluded(filepath.Join(dir, e.Name()), e.IsDir(), ign)
+	})
+	slices.SortFunc(entries, func(e1, e2 os.DirEntry) int {
+		if e1.IsDir() != e2.IsDir() {
+			if e1.IsDir() {
+				return -1
+			} else {
+				return 1
+			}
+		}
+		return strings.Compare(e1.Name(), e2.Name())
+	})
+	return entries
+}
+
+// As the fallback, but without checking again what browsableEntries has
+func entryFile(dir string, entry os.DirEntry) (*File, bool) {
+	var osPath = filepath.Join(dir, entry.Name())
+	if f, ok := FileMap.Get(key(osPath)); ok {
+		return f, true
+	} else {
+		var f, err = makeFileFromPath(osPath)
+		return f, err == nil && f != nil
+	}
+}
+
+type fileProvider struct {
+	search.Provider
+}
+
+// path is a resource path, eg. '/file/home/joe'
+func (this fileProvider) Children(path string) ([]entity.Base, bool) {
+	var prefix = FileMap.GetPrefix()
+	if !strings.HasPrefix(path, prefix) {
+		return nil, false
+	} else if dir, ok := FileMap.Find(strings.TrimPrefix(path, prefix)); !ok || dir.Type != "Directory" {
+		return nil, false
+	} else {
+		var children = []entity.Base{}
+		for _, entry := range browsableEntries(dir.OsPath) {
+			if child, ok := entryFile(dir.OsPath, entry); ok {
+				children = append(children, child.Base)
+			}
+		}
+		return children, true
+	}
+}
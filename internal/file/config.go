@@ -0,0 +1,143 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package file
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/surlykke/refude/internal/lib/utils"
+	"github.com/surlykke/refude/internal/lib/xdg"
+)
+
+/*
+* What to index may be configured in $XDG_CONFIG_HOME/refude/files.ini, eg:
+*
+*    [Index]
+*    Hidden=false
+*    Ignore=node_modules;*.o;target/
+*
+*    [Root ~]
+*    Depth=1
+*
+*    [Root ~/Documents]
+*    Depth=6
+*
+* Depth is how many levels of directories below a root are indexed: 1 means the entries of the root itself.
+* Ignore takes patterns like those of .gitignore, applying everywhere. Also, .gitignore and .refudeignore files
+* found while indexing are respected for the directory they're in and below. Hidden files (dot-files) are
+* only indexed if Hidden is true.
+*
+* If no roots are configured, home is indexed to depth 1, and the xdg user dirs (Documents, Downloads,...)
+* to depth 4.
+ */
+
+type root struct {
+	dir   string
+	depth int
+}
+
+type config struct {
+	roots  []root
+	hidden bool
+	ignore []string
+}
+
+var configPath = xdg.ConfigHome + "/refude/files.ini"
+
+func readConfig() config {
+	var c config
+	if _, err := os.Stat(configPath); err == nil {
+		if iniFile, err := xdg.ReadIniFile(configPath); err != nil {
+			log.Print("Could not read ", configPath, ": ", err)
+		} else {
+			for _, group := range iniFile {
+				if group.Name == "Index" {
+					c.hidden = group.Entries["Hidden"] == "true"
+					c.ignore = utils.Split(group.Entries["Ignore"], ";")
+				} else if dir, ok := strings.CutPrefix(group.Name, "Root "); ok {
+					var depth = 1
+					if d, err := strconv.Atoi(group.Entries["Depth"]); err == nil && d > 0 {
+						depth = d
+					} else if group.Entries["Depth"] != "" {
+						log.Print(configPath, ", ", group.Name, ": bad Depth: ", group.Entries["Depth"])
+					}
+					c.roots = append(c.roots, root{dir: expandHome(dir), depth: depth})
+				} else {
+					log.Print(configPath, ": unknown group ", group.Name)
+				}
+			}
+		}
+	}
+
+	if len(c.roots) == 0 {
+		c.roots = []root{{xdg.Home, 1}}
+		for _, dir := range []string{xdg.DesktopDir, xdg.DownloadDir, xdg.TemplatesDir, xdg.PublicshareDir, xdg.DocumentsDir, xdg.MusicDir, xdg.PicturesDir, xdg.VideosDir} {
+			c.roots = append(c.roots, root{dir, 4})
+		}
+	}
+	return c
+}
+
+func (this config) excluded(osPath string, isDir bool, ign ignorer) bool {
+	return (!this.hidden && strings.HasPrefix(filepath.Base(osPath), ".")) || ign.ignored(osPath, isDir)
+}
+
+/*
+* Whether osPath is a root, or below one and not excluded by the rules the indexer goes by, looking at each
+* directory on the way down. If so, also returns the ignorer applying to osPath's entries.
+ */
+func (this config) admits(osPath string, isDir bool) (ignorer, bool) {
+	for _, r := range this.roots {
+		var rel, ok = relativeTo(r.dir, osPath)
+		if !ok {
+			continue
+		}
+		var dir, ign = r.dir, makeIgnorer(this.ignore).withDir(r.dir)
+		var admitted = true
+		if rel != "" {
+			var parts = strings.Split(rel, "/")
+			for i, part := range parts {
+				dir = filepath.Join(dir, part)
+				var partIsDir = isDir || i < len(parts)-1
+				if this.excluded(dir, partIsDir, ign) {
+					admitted = false
+					break
+				} else if partIsDir {
+					ign = ign.withDir(dir)
+				}
+			}
+		}
+		if admitted {
+			return ign, true
+		}
+	}
+	return nil, false
+}
+
+// osPath relative to dir, if it is dir or below it
+func relativeTo(dir string, osPath string) (string, bool) {
+	if osPath == dir {
+		return "", true
+	} else if dir == "/" {
+		return strings.CutPrefix(osPath, "/")
+	} else {
+		return strings.CutPrefix(osPath, dir+"/")
+	}
+}
+
+func expandHome(dir string) string {
+	if dir == "~" {
+		return xdg.Home
+	} else if rest, ok := strings.CutPrefix(dir, "~/"); ok {
+		return filepath.Clean(xdg.Home + "/" + rest)
+	} else {
+		return filepath.Clean(dir)
+	}
+}
@@ -6,6 +6,7 @@
 package file
 
 import (
+	"errors"
 	"io/fs"
 	"log"
 	"os"
@@ -15,6 +16,8 @@ import (
 	"github.com/rakyll/magicmime"
 	"github.com/surlykke/refude/internal/applications"
 	"github.com/surlykke/refude/internal/lib/entity"
+	"github.com/surlykke/refude/internal/lib/xdg"
+	"github.com/surlykke/refude/internal/trash"
 	"github.com/surlykke/refude/pkg/bind"
 )
 
@@ -66,13 +69,27 @@ func makeFileFromPath(path string) (*File, error) {
 }
 
 func MimeType(ospath string) string {
-	var s, _ = magicmime.TypeByFile(ospath)
-	return s
+	if fileInfo, err := os.Stat(ospath); err != nil {
+		return ""
+	} else {
+		return mimetypeOf(ospath, fileInfo)
+	}
+}
+
+// The mimetype of any file, indexed or not. path must be absolute
+func MimetypeHandler(path string) bind.Response {
+	if !filepath.IsAbs(path) {
+		return bind.UnprocessableEntity(errors.New("path must be absolute"))
+	} else if mimetype := MimeType(filepath.Clean(path)); mimetype == "" {
+		return bind.NotFound()
+	} else {
+		return bind.Json(map[string]string{"Mimetype": mimetype})
+	}
 }
 
 func makeFileFromInfo(osPath string, fileInfo os.FileInfo) *File {
 	var fileType = getFileType(fileInfo.Mode())
-	var mimetype, _ = magicmime.TypeByFile(osPath)
+	var mimetype = mimetypeOf(osPath, fileInfo)
 	var icon = strings.ReplaceAll(mimetype, "/", "-")
 	var f = File{
 		Base:        *entity.MakeBase(fileInfo.Name(), osPath, icon, "File"),
@@ -82,11 +99,18 @@ func makeFileFromInfo(osPath string, fileInfo os.FileInfo) *File {
 		Mimetype:    mimetype,
 		OsPath:      osPath,
 	}
+	f.Meta.Browsable = fileType == "Directory"
+	f.addActions(applications.GetHandlers(f.Mimetype))
+	return &f
+}
 
-	for _, app := range applications.GetHandlers(f.Mimetype) {
+func (f *File) addActions(handlers []*applications.DesktopApplication) {
+	for _, app := range handlers {
 		f.AddAction(app.DesktopId, app.Title, app.Icon)
 	}
-	return &f
+	f.AddAction(revealAction, "Show in file manager", "system-file-manager")
+	f.AddAction(copyPathAction, "Copy path", "edit-copy")
+	f.AddDeleteAction("Move to trash", "user-trash")
 }
 
 func readEntries(dir string) []fs.DirEntry {
@@ -101,13 +125,60 @@ func readEntries(dir string) []fs.DirEntry {
 	}
 }
 
+/*
+* Besides opening the file with one of its handler apps (action being the app's desktop id), files may be
+* revealed in the file manager or have their path copied to the clipboard. Action ids of our own start with ':',
+* which desktop ids don't. Trashing is done with DELETE.
+*
+* With no action given, the file is opened with its default app, if it has one.
+ */
+const (
+	revealAction   = ":reveal"
+	copyPathAction = ":copy-path"
+)
+
 func (f *File) DoPost(action string) bind.Response {
-	if action == "" && len(f.Meta.Actions) > 0 {
+	if action == "" {
+		if len(f.Meta.Actions) == 0 || strings.HasPrefix(f.Meta.Actions[0].Id, ":") {
+			return bind.NotFound()
+		}
 		action = f.Meta.Actions[0].Id
 	}
+	switch action {
+	case revealAction:
+		return f.reveal()
+	case copyPathAction:
+		if err := xdg.RunCmd("wl-copy", "--", f.OsPath); err != nil {
+			return bind.ServerError(err)
+		}
+		return bind.Accepted()
+	}
 	if applications.OpenFile(action, f.OsPath) {
 		return bind.Accepted()
 	} else {
 		return bind.NotFound()
 	}
 }
+
+// Opens the directory containing the file with the file manager
+func (f *File) reveal() bind.Response {
+	var fileManagers = applications.GetHandlers("inode/directory")
+	if len(fileManagers) == 0 {
+		return bind.NotFound()
+	} else if err := fileManagers[0].Run(filepath.Dir(f.OsPath)); err != nil {
+		return bind.ServerError(err)
+	} else {
+		return bind.Accepted()
+	}
+}
+
+// Moves the file to trash
+func (f *File) DoDelete() bind.Response {
+	if err := trash.Trash(f.OsPath); errors.Is(err, trash.ErrOtherFilesystem) {
+		return bind.UnprocessableEntity(err)
+	} else if err != nil {
+		return bind.ServerError(err)
+	} else {
+		return bind.Accepted()
+	}
+}
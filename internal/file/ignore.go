@@ -0,0 +1,119 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package file
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"strings"
+)
+
+/*
+* A subset of .gitignore syntax:
+*
+*   - Blank lines and lines starting with '#' are skipped
+*   - A leading '!' negates the pattern: what it matches is not ignored, after all
+*   - A trailing '/' makes the pattern match directories only
+*   - A pattern containing a '/' (other than trailing) is relative to the directory of the ignore file
+*     (for rules from configuration, the root directory). Otherwise it matches names at any level below.
+*     A leading '**' / is dropped.
+*   - Patterns are globs as understood by path.Match
+*
+* As with git, the last rule matching decides.
+ */
+
+var ignoreFileNames = []string{".gitignore", ".refudeignore"}
+
+type ignoreRule struct {
+	base     string // Dir of the ignore file. Empty for rules from configuration, applying everywhere
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+type ignorer []ignoreRule
+
+func makeIgnoreRule(base string, line string) (ignoreRule, bool) {
+	var rule = ignoreRule{base: base}
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule, false
+	}
+	if rest, ok := strings.CutPrefix(line, "!"); ok {
+		rule.negate, line = true, rest
+	}
+	if rest, ok := strings.CutSuffix(line, "/"); ok {
+		rule.dirOnly, line = true, rest
+	}
+	line = strings.TrimPrefix(line, "**/")
+	if strings.Contains(line, "/") {
+		rule.anchored, line = true, strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return rule, false
+	}
+	rule.pattern = line
+	return rule, true
+}
+
+func makeIgnorer(patterns []string) ignorer {
+	var result = ignorer{}
+	for _, pattern := range patterns {
+		if rule, ok := makeIgnoreRule("", pattern); ok {
+			result = append(result, rule)
+		}
+	}
+	return result
+}
+
+// Returns this with the rules of any ignore files in dir appended
+func (this ignorer) withDir(dir string) ignorer {
+	var result = this
+	for _, name := range ignoreFileNames {
+		if file, err := os.Open(dir + "/" + name); err == nil {
+			if len(result) == len(this) {
+				result = append(ignorer{}, this...) // Don't share backing array with siblings
+			}
+			var scanner = bufio.NewScanner(file)
+			for scanner.Scan() {
+				if rule, ok := makeIgnoreRule(dir, scanner.Text()); ok {
+					result = append(result, rule)
+				}
+			}
+			file.Close()
+		}
+	}
+	return result
+}
+
+func (this ignorer) ignored(osPath string, isDir bool) bool {
+	var ignored = false
+	for _, rule := range this {
+		if rule.matches(osPath, isDir) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+func (this ignoreRule) matches(osPath string, isDir bool) bool {
+	if this.dirOnly && !isDir {
+		return false
+	}
+	var relPath, ok = strings.CutPrefix(osPath, this.base+"/")
+	if !ok {
+		return false
+	}
+	if this.anchored {
+		var matched, _ = path.Match(this.pattern, relPath)
+		return matched
+	} else {
+		var matched, _ = path.Match(this.pattern, path.Base(relPath))
+		return matched
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMakeIgnoreRule(t *testing.T) {
+	var tests = []struct {
+		line string
+		ok   bool
+		rule ignoreRule
+	}{
+		{"*.log", true, ignoreRule{base: "/p", pattern: "*.log"}},
+		{"*.log  \r", true, ignoreRule{base: "/p", pattern: "*.log"}},
+		{"!keep.log", true, ignoreRule{base: "/p", pattern: "keep.log", negate: true}},
+		{"build/", true, ignoreRule{base: "/p", pattern: "build", dirOnly: true}},
+		{"/build", true, ignoreRule{base: "/p", pattern: "build", anchored: true}},
+		{"doc/*.txt", true, ignoreRule{base: "/p", pattern: "doc/*.txt", anchored: true}},
+		{"**/foo", true, ignoreRule{base: "/p", pattern: "foo"}},
+		{"**/foo/bar", true, ignoreRule{base: "/p", pattern: "foo/bar", anchored: true}},
+		{"!/out/", true, ignoreRule{base: "/p", pattern: "out", negate: true, dirOnly: true, anchored: true}},
+		{"", false, ignoreRule{}},
+		{"   ", false, ignoreRule{}},
+		{"# comment", false, ignoreRule{}},
+		{"!", false, ignoreRule{}},
+		{"/", false, ignoreRule{}},
+	}
+	for _, test := range tests {
+		if rule, ok := makeIgnoreRule("/p", test.line); ok != test.ok || (ok && rule != test.rule) {
+			t.Errorf("'%s': got %+v, %t, want %+v, %t", test.line, rule, ok, test.rule, test.ok)
+		}
+	}
+}
+
+func TestIgnored(t *testing.T) {
+	var rules = func(lines ...string) ignorer {
+		var result = ignorer{}
+		for _, line := range lines {
+			if rule, ok := makeIgnoreRule("/p", line); ok {
+				result = append(result, rule)
+			}
+		}
+		return result
+	}
+	var tests = []struct {
+		ignorer ignorer
+		osPath  string
+		isDir   bool
+		ignored bool
+	}{
+		// Unanchored patterns match names at any level below the ignore file, but not outside it
+		{rules("*.log"), "/p/a.log", false, true},
+		{rules("*.log"), "/p/sub/dir/a.log", false, true},
+		{rules("*.log"), "/p/a.txt", false, false},
+		{rules("*.log"), "/q/a.log", false, false},
+		{rules("*.log"), "/pp/a.log", false, false},
+		{rules("**/foo"), "/p/a/foo", false, true},
+
+		// Trailing slash: directories only
+		{rules("build/"), "/p/build", true, true},
+		{rules("build/"), "/p/build", false, false},
+		{rules("build/"), "/p/sub/build", true, true},
+
+		// Anchored, by a leading slash or one within
+		{rules("/build"), "/p/build", true, true},
+		{rules("/build"), "/p/sub/build", true, false},
+		{rules("doc/*.txt"), "/p/doc/a.txt", false, true},
+		{rules("doc/*.txt"), "/p/x/doc/a.txt", false, false},
+		{rules("doc/*.txt"), "/p/doc/sub/a.txt", false, false},
+		{rules("/out/"), "/p/out", true, true},
+		{rules("/out/"), "/p/out", false, false},
+
+		// Negation, the last rule matching deciding
+		{rules("*.log", "!keep.log"), "/p/keep.log", false, false},
+		{rules("*.log", "!keep.log"), "/p/other.log", false, true},
+		{rules("!keep.log", "*.log"), "/p/keep.log", false, true},
+		{rules("!keep.log"), "/p/keep.log", false, false},
+		{rules("tmp/", "!tmp/"), "/p/tmp", true, false},
+
+		// Rules from configuration apply everywhere
+		{makeIgnorer([]string{"node_modules/"}), "/anywhere/node_modules", true, true},
+		{makeIgnorer([]string{"/cache"}), "/cache", true, true},
+		{makeIgnorer([]string{"/cache"}), "/home/cache", true, false},
+		{ignorer{}, "/p/a.log", false, false},
+	}
+	for _, test := range tests {
+		if ignored := test.ignorer.ignored(test.osPath, test.isDir); ignored != test.ignored {
+			t.Errorf("%+v, %s (dir: %t): got %t, want %t", test.ignorer, test.osPath, test.isDir, ignored, test.ignored)
+		}
+	}
+}
+
+func TestWithDir(t *testing.T) {
+	var dir = t.TempDir()
+	var write = func(name string, content string) {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		} else if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(".gitignore", "*.log\n# comment\n\nbuild/\n")
+	write(".refudeignore", "!keep.log\n")
+	write("a/.gitignore", "*.tmp\n")
+	os.Mkdir(filepath.Join(dir, "b"), 0755)
+
+	var global = makeIgnorer([]string{"*.bak"})
+	var top = global.withDir(dir)
+	if len(top) != 4 {
+		t.Errorf("got %d rules, want 4", len(top))
+	}
+	var tests = []struct {
+		ignorer ignorer
+		osPath  string
+		isDir   bool
+		ignored bool
+	}{
+		{top, dir + "/x.log", false, true},
+		{top, dir + "/keep.log", false, false}, // .refudeignore is read after .gitignore
+		{top, dir + "/build", true, true},
+		{top, dir + "/x.bak", false, true},
+		{top, dir + "/x.tmp", false, false},
+		{top.withDir(dir + "/a"), dir + "/a/x.tmp", false, true},
+		{top.withDir(dir + "/a"), dir + "/a/x.log", false, true},
+		{top.withDir(dir + "/b"), dir + "/b/x.tmp", false, false},
+	}
+	for _, test := range tests {
+		if ignored := test.ignorer.ignored(test.osPath, test.isDir); ignored != test.ignored {
+			t.Errorf("%s (dir: %t): got %t, want %t", test.osPath, test.isDir, ignored, test.ignored)
+		}
+	}
+
+	// Siblings must not see each other's rules
+	var a, b = top.withDir(dir + "/a"), top.withDir(dir + "/b")
+	if len(a) != 5 || len(b) != 4 || !a.ignored(dir+"/a/x.tmp", false) {
+		t.Errorf("siblings share rules: %+v, %+v", a, b)
+	}
+	if len(global.withDir(dir+"/b")) != len(global) {
+		t.Error("a dir without ignore files added rules")
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package file
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rakyll/magicmime"
+	"github.com/surlykke/refude/internal/lib/xdg"
+)
+
+/*
+* Determining mimetypes with libmagic is slow, so we remember them between runs, along with modification time
+* and size of the file. If those haven't changed, we trust the remembered mimetype.
+*
+* Libmagic is not threadsafe, so all calls to it go through here, holding mimeCacheLock.
+ */
+
+type cachedMimetype struct {
+	Mimetype string    `json:"mimetype"`
+	ModTime  time.Time `json:"modTime"`
+	Size     int64     `json:"size"`
+}
+
+var mimeCache map[string]cachedMimetype
+var mimeCacheDirty bool
+var mimeCacheLock sync.Mutex
+var mimeCachePath = xdg.CacheHome + "/refude/files.json"
+
+func mimetypeOf(osPath string, info os.FileInfo) string {
+	mimeCacheLock.Lock()
+	defer mimeCacheLock.Unlock()
+	loadMimeCache()
+	if cached, ok := mimeCache[osPath]; ok && cached.ModTime.Equal(info.ModTime()) && cached.Size == info.Size() {
+		return cached.Mimetype
+	}
+	var mimetype, _ = magicmime.TypeByFile(osPath)
+	mimeCache[osPath] = cachedMimetype{Mimetype: mimetype, ModTime: info.ModTime(), Size: info.Size()}
+	mimeCacheDirty = true
+	return mimetype
+}
+
+func forgetMimetype(osPath string) {
+	mimeCacheLock.Lock()
+	defer mimeCacheLock.Unlock()
+	loadMimeCache()
+	if _, ok := mimeCache[osPath]; ok {
+		delete(mimeCache, osPath)
+		mimeCacheDirty = true
+	}
+}
+
+// Drops cached mimetypes of files not in keep
+func pruneMimeCache(keep func(osPath string) bool) {
+	mimeCacheLock.Lock()
+	defer mimeCacheLock.Unlock()
+	loadMimeCache()
+	for osPath := range mimeCache {
+		if !keep(osPath) {
+			delete(mimeCache, osPath)
+			mimeCacheDirty = true
+		}
+	}
+}
+
+// Callers must hold mimeCacheLock
+func loadMimeCache() {
+	if mimeCache != nil {
+		return
+	}
+	mimeCache = map[string]cachedMimetype{}
+	if err := xdg.ReadJsonFile(mimeCachePath, &mimeCache); err != nil {
+		log.Print("Could not read ", mimeCachePath, ": ", err)
+		mimeCache = map[string]cachedMimetype{}
+	}
+}
+
+func saveMimeCache() {
+	mimeCacheLock.Lock()
+	defer mimeCacheLock.Unlock()
+	if !mimeCacheDirty {
+		return
+	}
+	if err := xdg.WriteJsonFile(mimeCachePath, mimeCache); err != nil {
+		log.Print("Could not write ", mimeCachePath, ": ", err)
+	} else {
+		mimeCacheDirty = false
+	}
+}
@@ -6,72 +6,269 @@
 package file
 
 import (
+	"errors"
 	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/surlykke/refude/internal/applications"
 	"github.com/surlykke/refude/internal/lib/entity"
 	"github.com/surlykke/refude/internal/lib/xdg"
+	"github.com/surlykke/refude/internal/search"
 )
 
 var FileMap = entity.MakeMap[string, *File]()
 
-func Run() {
-	var watchedDirs []string
+func init() {
+	search.Register(fileProvider{search.MapProvider("files", 3, 0, FileMap)})
+}
+
+// A directory whose entries we index (and watch)
+type dirInfo struct {
+	remaining int             // How many levels, including this, to index
+	inherited ignorer         // The rules from above
+	ignorer   ignorer         // inherited and the rules of the dir's own ignore files
+	children  map[string]bool // Names of the entries indexed
+}
 
+type indexer struct {
+	config  config
+	watcher *fsnotify.Watcher
+	dirs    map[string]dirInfo
+	pending map[string]bool // Created or written, waiting for things to settle
+}
+
+func Run() {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		panic(err)
 	}
+	var ix = &indexer{watcher: watcher, dirs: map[string]dirInfo{}, pending: map[string]bool{}}
+	ix.rebuild()
 
-	for _, dir := range []string{xdg.Home, xdg.DesktopDir, xdg.DownloadDir, xdg.TemplatesDir, xdg.PublicshareDir, xdg.DocumentsDir, xdg.MusicDir, xdg.PicturesDir, xdg.VideosDir} {
-		if xdg.DirOrFileExists(dir) {
-			if err := watcher.Add(dir); err != nil {
-				log.Print("Not watching", dir, err)
-			} else {
-				watchedDirs = append(watchedDirs, dir)
-			}
-		}
-	}
-
-	var scanEv = make(chan struct{})
-	var scanScheduled = false
-	scanDirs(watchedDirs)
+	var appEvents = make(chan struct{})
 	go func() {
 		var appSubscription = applications.AppEvents.Subscribe()
 		for {
-			scanEv <- appSubscription.Next()
-
+			appEvents <- appSubscription.Next()
 		}
 	}()
 
+	var flushEv = make(chan struct{})
+	var flushScheduled = false
+	var saveTicker = time.NewTicker(time.Minute)
+
 	for {
 		select {
-		case <-watcher.Events:
-			// We collect events for a second before scanning, as they can come in bursts
-			if !scanScheduled {
-				scanScheduled = true
-				go func() { time.Sleep(1 * time.Second); scanEv <- struct{}{} }()
+		case ev := <-watcher.Events:
+			if ix.handle(ev) && !flushScheduled {
+				// Files being written often give a burst of events, so we wait a second before looking at them
+				flushScheduled = true
+				go func() { time.Sleep(1 * time.Second); flushEv <- struct{}{} }()
+			}
+		case <-flushEv:
+			flushScheduled = false
+			ix.flush()
+		case err := <-watcher.Errors:
+			log.Print("Watching files: ", err)
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				ix.rebuild()
 			}
-		case <-scanEv:
-			scanScheduled = false
-			scanDirs(watchedDirs)
+		case <-appEvents:
+			ix.refreshActions()
+		case <-saveTicker.C:
+			saveMimeCache()
 		}
 	}
+}
 
+func key(osPath string) string {
+	return osPath[1:]
 }
 
-func scanDirs(watchedDirs []string) {
-	var collected = make(map[string]*File, 50)
-	for _, dir := range watchedDirs {
-		for _, entry := range readEntries(dir) {
-			var name = entry.Name()
-			var path = dir + "/" + name
-			if file, err := makeFileFromPath(path); err == nil {
-				collected[path[1:]] = file
-			}
+// (Re)reads configuration and indexes everything
+func (this *indexer) rebuild() {
+	this.config = readConfig()
+	browseConfig.Store(&this.config)
+	var oldDirs = this.dirs
+	this.dirs = map[string]dirInfo{}
+	clear(this.pending)
+
+	var collected = make(map[string]*File, 1000)
+	var global = makeIgnorer(this.config.ignore)
+	for _, r := range this.config.roots {
+		if xdg.DirOrFileExists(r.dir) {
+			this.scan(r.dir, r.depth, global, collected)
+		}
+	}
+	for dir := range oldDirs {
+		if _, ok := this.dirs[dir]; !ok {
+			this.watcher.Remove(dir)
 		}
 	}
+
 	FileMap.ReplaceAll(collected)
+	pruneMimeCache(func(osPath string) bool { _, ok := collected[key(osPath)]; return ok })
+	saveMimeCache()
+}
+
+// inherited being the ignore rules from above dir
+func (this *indexer) scan(dir string, remaining int, inherited ignorer, collected map[string]*File) {
+	if info, ok := this.dirs[dir]; ok && info.remaining >= remaining {
+		return // Covered by another root
+	}
+	var ign = inherited.withDir(dir)
+	var children = map[string]bool{}
+	this.dirs[dir] = dirInfo{remaining: remaining, inherited: inherited, ignorer: ign, children: children}
+	if err := this.watcher.Add(dir); err != nil {
+		log.Print("Not watching ", dir, ": ", err)
+	}
+
+	for _, entry := range readEntries(dir) {
+		var osPath = filepath.Join(dir, entry.Name())
+		if this.config.excluded(osPath, entry.IsDir(), ign) {
+			continue
+		}
+		if fileInfo, err := os.Stat(osPath); err == nil {
+			collected[key(osPath)] = makeFileFromInfo(osPath, fileInfo)
+			children[entry.Name()] = true
+		}
+		// IsDir is false for symlinks, so we don't follow them into loops
+		if entry.IsDir() && remaining > 1 {
+			this.scan(osPath, remaining-1, ign, collected)
+		}
+	}
+}
+
+// Returns true if the event left something to flush
+func (this *indexer) handle(ev fsnotify.Event) bool {
+	var dir, name = filepath.Dir(ev.Name), filepath.Base(ev.Name)
+	if _, ok := this.dirs[ev.Name]; ok && (ev.Has(fsnotify.Remove) || ev.Has(fsnotify.Rename)) {
+		// A directory we watch has gone. Its parent may not be watched, if it's a root
+		delete(this.pending, ev.Name)
+		this.remove(ev.Name)
+		return false
+	} else if _, ok := this.dirs[dir]; !ok {
+		return false
+	} else if slices.Contains(ignoreFileNames, name) {
+		this.rescan(dir)
+		return false
+	} else if ev.Has(fsnotify.Remove) || ev.Has(fsnotify.Rename) {
+		// A rename gives a create for the new name
+		delete(this.pending, ev.Name)
+		this.remove(ev.Name)
+		return false
+	} else if ev.Has(fsnotify.Create) || ev.Has(fsnotify.Write) || ev.Has(fsnotify.Chmod) {
+		this.pending[ev.Name] = true
+		return true
+	} else {
+		return false
+	}
+}
+
+func (this *indexer) flush() {
+	var collected = map[string]*File{}
+	for osPath := range this.pending {
+		var info, ok = this.dirs[filepath.Dir(osPath)]
+		if !ok {
+			continue
+		}
+		var fileInfo, err = os.Lstat(osPath)
+		if err != nil {
+			this.remove(osPath)
+			continue
+		}
+		var isDir = fileInfo.IsDir()
+		if this.config.excluded(osPath, isDir, info.ignorer) {
+			continue
+		}
+		if statInfo, err := os.Stat(osPath); err == nil {
+			fileInfo = statInfo // As makeFileFromPath does, we describe what links point to
+		}
+		collected[key(osPath)] = makeFileFromInfo(osPath, fileInfo)
+		info.children[filepath.Base(osPath)] = true
+		if isDir && info.remaining > 1 {
+			this.scan(osPath, info.remaining-1, info.ignorer, collected)
+		}
+	}
+	clear(this.pending)
+	FileMap.Replace(collected, func(*File) bool { return false })
+}
+
+// Indexes dir and what is below it again, eg. when an ignore file in it has changed
+func (this *indexer) rescan(dir string) {
+	for _, r := range this.config.roots {
+		if strings.HasPrefix(r.dir, dir+"/") {
+			this.rebuild() // Roots within roots. Simpler to start over
+			return
+		}
+	}
+	var info = this.dirs[dir]
+	var gone = map[string]bool{}
+	this.forget(dir, gone)
+	delete(gone, dir) // dir itself stays
+	var collected = map[string]*File{}
+	this.scan(dir, info.remaining, info.inherited, collected)
+	this.drop(gone, collected)
+}
+
+// Handler apps may have changed. Files whose actions then differ are given new ones
+func (this *indexer) refreshActions() {
+	var handlers = map[string][]*applications.DesktopApplication{} // By mimetype
+	var changed = map[string]*File{}
+	for _, f := range FileMap.GetAll() {
+		var apps, ok = handlers[f.Mimetype]
+		if !ok {
+			apps = applications.GetHandlers(f.Mimetype)
+			handlers[f.Mimetype] = apps
+		}
+		var updated = *f
+		updated.Meta.Actions = nil
+		updated.addActions(apps)
+		if !slices.Equal(updated.Meta.Actions, f.Meta.Actions) {
+			changed[key(f.OsPath)] = &updated
+		}
+	}
+	FileMap.Replace(changed, func(*File) bool { return false })
+}
+
+// Removes osPath and, if it's a directory, everything indexed below it
+func (this *indexer) remove(osPath string) {
+	if parent, ok := this.dirs[filepath.Dir(osPath)]; ok {
+		delete(parent.children, filepath.Base(osPath))
+	}
+	var gone = map[string]bool{}
+	this.forget(osPath, gone)
+	this.drop(gone, map[string]*File{})
+}
+
+// Drops osPath and, if it's a directory, everything indexed below it from dirs. What is dropped goes into gone,
+// mapped to whether it was a directory
+func (this *indexer) forget(osPath string, gone map[string]bool) {
+	var info, isDir = this.dirs[osPath]
+	gone[osPath] = isDir
+	if isDir {
+		delete(this.dirs, osPath)
+		for name := range info.children {
+			this.forget(filepath.Join(osPath, name), gone)
+		}
+	}
+}
+
+// Puts reindexed in FileMap, in one go, taking out what is gone and not reindexed. Directories gone, and not
+// indexed again, are no longer watched
+func (this *indexer) drop(gone map[string]bool, reindexed map[string]*File) {
+	for osPath, isDir := range gone {
+		if _, ok := reindexed[key(osPath)]; !ok {
+			forgetMimetype(osPath)
+		}
+		if _, ok := this.dirs[osPath]; isDir && !ok {
+			this.watcher.Remove(osPath)
+		}
+	}
+	FileMap.Replace(reindexed, func(f *File) bool { _, ok := gone[f.OsPath]; return ok })
 }
@@ -15,11 +15,16 @@ import (
 
 	"github.com/surlykke/refude/internal/lib/entity"
 	"github.com/surlykke/refude/internal/lib/image"
+	"github.com/surlykke/refude/internal/search"
 	"github.com/surlykke/refude/pkg/bind"
 )
 
 var ThemeMap = entity.MakeMap[string, *IconTheme]()
 
+func init() {
+	search.RegisterLookup(ThemeMap)
+}
+
 func Run() {
 	collectThemes()
 	collectIcons()
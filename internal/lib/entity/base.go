@@ -6,7 +6,7 @@
 package entity
 
 import (
-	"encoding/json"
+	"net/url"
 	"slices"
 	"strings"
 
@@ -28,13 +28,33 @@ type Base struct {
 }
 
 type Meta struct {
-	Path     string
-	Actions  []Action
-	Keywords []string // TODO Maybe a function, including keywords from actions
+	Path            string
+	Actions         []Action
+	DeleteAction    *Action
+	Keywords        []string // TODO Maybe a function, including keywords from actions
+	AcceptsArgument bool     // Actions may be given an argument. The entity should implement ArgumentPostable
+	Browsable       bool     // Has children, eg. a directory. Its search provider should implement search.Browser
+	Extra           []Link   // Links beyond self, actions and delete, eg. to children or parent
 }
 
 func (this *Meta) MarshalJSON() ([]byte, error) {
-	return json.Marshal(buildLinks(this))
+	return bind.ToJson(buildLinks(this)), nil // Not json.Marshal, which would escape '&' in hrefs
+}
+
+func (this *Meta) JSONSchema() map[string]any {
+	return map[string]any{
+		"type": "array",
+		"items": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"href":  map[string]any{"type": "string"},
+				"title": map[string]any{"type": "string"},
+				"icon":  map[string]any{"type": "string"},
+				"rel":   map[string]any{"type": "string", "enum": []string{Self, Icon, Related, Parent, Next, OrgRefudeAction, OrgRefudeDelete, OrgRefudeMenu}},
+			},
+			"required": []string{"href"},
+		},
+	}
 }
 
 type Action struct {
@@ -71,7 +91,7 @@ func (this *Base) GetBase() *Base {
 }
 
 func (this *Base) Links(rel ...Relation) []Link {
-	return buildLinks(&this.Meta)
+	return buildLinks(&this.Meta, rel...)
 }
 
 func buildLinks(meta *Meta, rel ...Relation) []Link {
@@ -88,6 +108,14 @@ func buildLinks(meta *Meta, rel ...Relation) []Link {
 			links = append(links, Link{Href: href, Title: action.Name, Icon: action.Icon, Relation: OrgRefudeAction})
 		}
 	}
+	if meta.DeleteAction != nil && (len(rel) == 0 || slices.Index(rel, OrgRefudeDelete) > -1) {
+		links = append(links, Link{Href: meta.Path, Title: meta.DeleteAction.Name, Icon: meta.DeleteAction.Icon, Relation: OrgRefudeDelete})
+	}
+	for _, link := range meta.Extra {
+		if len(rel) == 0 || slices.Index(rel, link.Relation) > -1 {
+			links = append(links, link)
+		}
+	}
 	return links
 }
 
@@ -96,9 +124,13 @@ func (this *Base) AddAction(id string, name string, icon string) {
 	this.Meta.Actions = append(this.Meta.Actions, Action{Id: id, Name: translate.Text(name)})
 }
 
-/*func (this *ResourceData) AddDeleteAction(actionId string, title string, comment string, iconName icon.Name) {
-	this.Links = append(this.Links, Link{Href: href.Of(this.Path).P("action", actionId), Title: title, Comment: comment, Icon: iconName, Relation: entity.Delete})
-}*/
+// Marks the entity as deletable. The entity should implement Deleteable
+func (this *Base) AddDeleteAction(name string, icon string) {
+	if icon != "" {
+		icon = adjustIcon(icon)
+	}
+	this.Meta.DeleteAction = &Action{Name: translate.Text(name), Icon: icon}
+}
 
 type Link struct {
 	Href     string   `json:"href"`
@@ -113,6 +145,8 @@ const (
 	Self            = "self"
 	Icon            = "icon"
 	Related         = "related"
+	Parent          = "parent"
+	Next            = "next"
 	OrgRefudeAction = "org.refude.action"
 	OrgRefudeDelete = "org.refude.delete"
 	OrgRefudeMenu   = "org.refude.menu"
@@ -124,6 +158,23 @@ type Postable interface {
 	DoPost(string) bind.Response
 }
 
+// Arguments are eg. files or urls to open, each a separate argument to the command run
+type ArgumentPostable interface {
+	DoPostWithArguments(action string, args []string) bind.Response
+}
+
+// For entities whose activations are not to be recorded (see ActivationRecorder), eg. calculations, which would
+// otherwise leave every expression ever copied behind
+type Unrecorded interface {
+	Unrecorded()
+}
+
 type Deleteable interface {
 	DoDelete() bind.Response
 }
+
+// For entities that, when served singly, have more to tell than what's held, eg. a directory listing its entries.
+// Returns what to serve in place of the entity
+type Expandable interface {
+	Expand(params url.Values) (any, error)
+}
@@ -7,9 +7,15 @@ package entity
 
 import (
 	"cmp"
+	"errors"
 	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
 	"sync"
 
+	"github.com/surlykke/refude/internal/watch"
 	"github.com/surlykke/refude/pkg/bind"
 )
 
@@ -17,6 +23,7 @@ type EntityMap[K cmp.Ordered, V Servable] struct {
 	m        map[K]V
 	lock     sync.Mutex
 	basepath string
+	fallback func(K) (V, bool)
 }
 
 func MakeMap[K cmp.Ordered, V Servable]() *EntityMap[K, V] {
@@ -34,6 +41,25 @@ func (this *EntityMap[K, V]) Get(k K) (V, bool) {
 	return v, ok
 }
 
+// Like Get, but entities not in the map may be provided by the fallback (see SetFallback)
+func (this *EntityMap[K, V]) Find(k K) (V, bool) {
+	if v, ok := this.Get(k); ok {
+		return v, true
+	} else if this.fallback == nil {
+		return v, false
+	} else if v, ok = this.fallback(k); !ok {
+		return v, false
+	} else {
+		v.GetBase().Meta.Path = fmt.Sprintf("%s%v", this.basepath, k)
+		return v, true
+	}
+}
+
+// For entities that are servable by the map without being held by it, eg. files outside the index
+func (this *EntityMap[K, V]) SetFallback(fallback func(K) (V, bool)) {
+	this.fallback = fallback
+}
+
 func (this *EntityMap[K, V]) Put(k K, v V) {
 	this.lock.Lock()
 	defer this.lock.Unlock()
@@ -41,8 +67,14 @@ func (this *EntityMap[K, V]) Put(k K, v V) {
 }
 
 func (this *EntityMap[K, V]) put(k K, v V) {
+	var _, existed = this.m[k]
 	v.GetBase().Meta.Path = fmt.Sprintf("%s%v", this.basepath, k)
 	this.m[k] = v
+	if existed {
+		watch.ResourceChanged(v.GetBase().Meta.Path, watch.Updated)
+	} else {
+		watch.ResourceChanged(v.GetBase().Meta.Path, watch.Added)
+	}
 }
 
 func (this *EntityMap[K, V]) Remove(k K) (V, bool) {
@@ -55,36 +87,76 @@ func (this *EntityMap[K, V]) remove(k K) (V, bool) {
 	v, ok := this.m[k]
 	if ok {
 		delete(this.m, k)
+		watch.ResourceChanged(v.GetBase().Meta.Path, watch.Removed)
 	}
 	return v, ok
 }
 
 func (this *EntityMap[K, V]) Replace(newVals map[K]V, remove func(V) bool) {
 	this.lock.Lock()
-	defer this.lock.Unlock()
+	var oldVals = make(map[K]V)
 	for k, v := range this.m {
-		if remove(v) {
+		if _, replaced := newVals[k]; replaced || remove(v) {
+			oldVals[k] = v
 			delete(this.m, k)
 		}
 	}
 	for k, v := range newVals {
-		this.put(k, v)
+		this.m[k] = v
 	}
+	this.setPaths()
+	var changes = collectChanges(oldVals, newVals)
+	this.lock.Unlock()
+	publish(changes)
 }
 
 func (this *EntityMap[K, V]) ReplaceAll(newSet map[K]V) {
 	this.lock.Lock()
-	defer this.lock.Unlock()
+	var oldSet = this.m
 	this.m = newSet
 	this.setPaths()
+	var changes = collectChanges(oldSet, newSet)
+	this.lock.Unlock()
+	publish(changes)
+}
+
+type change struct {
+	path string
+	kind watch.ChangeKind
 }
 
+// Entities present in both old and new are reported as updated if they are not the same object. Callers must
+// hold the lock
+func collectChanges[K cmp.Ordered, V Servable](oldVals map[K]V, newVals map[K]V) []change {
+	var changes = []change{}
+	for k, v := range oldVals {
+		if _, ok := newVals[k]; !ok {
+			changes = append(changes, change{v.GetBase().Meta.Path, watch.Removed})
+		}
+	}
+	for k, v := range newVals {
+		if oldV, ok := oldVals[k]; !ok {
+			changes = append(changes, change{v.GetBase().Meta.Path, watch.Added})
+		} else if any(oldV) != any(v) {
+			changes = append(changes, change{v.GetBase().Meta.Path, watch.Updated})
+		}
+	}
+	return changes
+}
+
+func publish(changes []change) {
+	for _, c := range changes {
+		watch.ResourceChanged(c.path, c.kind)
+	}
+}
+
+// Returned list is ordered by key
 func (this *EntityMap[K, V]) GetAll() []V {
 	this.lock.Lock()
 	defer this.lock.Unlock()
 	var list = make([]V, 0, len(this.m))
-	for _, v := range this.m {
-		list = append(list, v)
+	for _, k := range slices.Sorted(maps.Keys(this.m)) {
+		list = append(list, this.m[k])
 	}
 	return list
 }
@@ -100,28 +172,80 @@ func (this *EntityMap[K, V]) GetForSearch() []Base {
 	return bases
 }
 
-func (this *EntityMap[K, V]) DoGet(id K) bind.Response {
-	if v, ok := this.Get(id); ok {
-		return bind.Json(v)
-	} else {
+func (this *EntityMap[K, V]) DoGet(id K, ifNoneMatch string, accept string, params url.Values) bind.Response {
+	if v, ok := this.Find(id); !ok {
 		return bind.NotFound()
+	} else if expandable, ok := any(v).(Expandable); !ok {
+		return represent(v, false, nil, accept, entityETag(v), ifNoneMatch)
+	} else if expanded, err := expandable.Expand(params); err != nil {
+		return bind.UnprocessableEntity(err)
+	} else {
+		// What's expanded (eg. a directory listing) may change without the entity changing, so no Not Modified
+		return represent(expanded, false, nil, accept, entityETag(v), "")
 	}
 }
 
-func (this *EntityMap[K, V]) DoGetList() bind.Response {
-	return bind.Json(this.GetAll())
+func (this *EntityMap[K, V]) DoGetList(ifNoneMatch string, accept string, params url.Values) bind.Response {
+	var columns []string
+	if accept != "" && bind.Negotiate(accept, offeredTypes()...) != "application/json" && params.Has("fields") {
+		// For tables, fields select columns rather than project
+		columns = strings.Split(params.Get("fields"), ",")
+		params = maps.Clone(params)
+		params.Del("fields")
+	}
+	if result, err := applyQuery(this.GetAll(), params); err != nil {
+		return bind.UnprocessableEntity(err)
+	} else {
+		return represent(result, true, columns, accept, entityETag(result), ifNoneMatch)
+	}
 }
 
-func (this *EntityMap[K, V]) DoPost(id K, action string) bind.Response {
-	if v, ok := this.Get(id); !ok {
+// term is what the user searched for to find the entity, if anything. Arguments may be given as query parameters
+// (args) and as a json list in the body (body), in that order
+func (this *EntityMap[K, V]) DoPost(id K, action string, args []string, body []string, ifMatch string, term string) bind.Response {
+	args = append(args, body...)
+	if v, ok := this.Find(id); !ok {
 		return bind.NotFound()
 	} else if postable, ok := any(v).(Postable); !ok {
 		return bind.NotAllowed()
+	} else if argumentPostable, ok := any(v).(ArgumentPostable); len(args) > 0 && !ok {
+		return bind.UnprocessableEntity(errors.New("does not take arguments"))
+	} else if !preconditionMet(v, ifMatch) {
+		return bind.PreconditionFailed()
 	} else {
-		return postable.DoPost(action)
+		var response bind.Response
+		if len(args) > 0 {
+			response = argumentPostable.DoPostWithArguments(action, args)
+		} else {
+			response = postable.DoPost(action)
+		}
+		if _, unrecorded := any(v).(Unrecorded); ActivationRecorder != nil && response.Status < 300 && !unrecorded {
+			ActivationRecorder(v.GetBase().Meta.Path, term)
+		}
+		return response
 	}
 }
 
+func (this *EntityMap[K, V]) DoDelete(id K, ifMatch string) bind.Response {
+	if v, ok := this.Find(id); !ok {
+		return bind.NotFound()
+	} else if deleteable, ok := any(v).(Deleteable); !ok {
+		return bind.NotAllowed()
+	} else if !preconditionMet(v, ifMatch) {
+		return bind.PreconditionFailed()
+	} else {
+		return deleteable.DoDelete()
+	}
+}
+
+// Told about successful posts. Set by whoever keeps track of them (the search package lives above us)
+var ActivationRecorder func(path string, term string)
+
+// An empty ifMatch means the client did not ask for a precondition
+func preconditionMet(v any, ifMatch string) bool {
+	return ifMatch == "" || bind.ETagMatches(ifMatch, entityETag(v))
+}
+
 func (this *EntityMap[K, V]) GetPaths() []string {
 	var paths = make([]string, 0, len(this.m))
 	this.lock.Lock()
@@ -132,6 +256,10 @@ func (this *EntityMap[K, V]) GetPaths() []string {
 	return paths
 }
 
+func (this *EntityMap[K, V]) GetPrefix() string {
+	return this.basepath
+}
+
 func (this *EntityMap[K, V]) SetPrefix(prefix string) {
 	this.basepath = prefix
 	this.setPaths()
@@ -0,0 +1,198 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package entity
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/surlykke/refude/pkg/bind"
+)
+
+/*
+* Filtering, sorting and projection of collections, done on the json representation of the entities, so
+* all collections get it.
+*
+* Query parameters other than 'sort', 'limit', 'offset' and 'fields' are filters: '?app_id=firefox' selects entities
+* whose json field 'app_id' has value 'firefox'. Field names are case insensitive, values may contain glob patterns
+* (as in path.Match), eg. '?mimetype=image/*'. A filter on a list field (eg. 'state=ACTIVATED') matches if any element matches.
+* When a filter parameter is repeated, an entity must match one of the given values.
+*
+*    sort=title          sort ascending by title. Prefix with '-' for descending
+*    offset=20&limit=10  paginate
+*    fields=title,links  only include the given fields
+ */
+
+type jsonField struct {
+	name  string // As it appears in the json
+	value any
+}
+
+type queryCandidate struct {
+	entity any
+	fields map[string]jsonField // Keyed by lowercased name
+}
+
+func applyQuery[V any](list []V, params url.Values) (any, error) {
+	if len(params) == 0 {
+		return list, nil
+	}
+
+	var candidates = make([]queryCandidate, 0, len(list))
+	for _, v := range list {
+		if candidate, err := makeQueryCandidate(v); err != nil {
+			return nil, err
+		} else if candidate.matches(params) {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	if sortBy := params.Get("sort"); sortBy != "" {
+		var descending = strings.HasPrefix(sortBy, "-")
+		var field = strings.ToLower(strings.TrimPrefix(sortBy, "-"))
+		slices.SortStableFunc(candidates, func(c1, c2 queryCandidate) int {
+			var v1, v2 = c1.fields[field].value, c2.fields[field].value
+			var res = compareJsonValues(v1, v2)
+			if descending && v1 != nil && v2 != nil {
+				res = -res
+			}
+			return res
+		})
+	}
+
+	if offsetParam := params.Get("offset"); offsetParam != "" {
+		if offset, err := strconv.Atoi(offsetParam); err != nil || offset < 0 {
+			return nil, errors.New("offset must be a non-negative integer")
+		} else {
+			candidates = candidates[min(offset, len(candidates)):]
+		}
+	}
+
+	if limitParam := params.Get("limit"); limitParam != "" {
+		if limit, err := strconv.Atoi(limitParam); err != nil || limit < 0 {
+			return nil, errors.New("limit must be a non-negative integer")
+		} else {
+			candidates = candidates[:min(limit, len(candidates))]
+		}
+	}
+
+	if fieldsParam := params.Get("fields"); fieldsParam != "" {
+		var projected = make([]map[string]any, 0, len(candidates))
+		for _, candidate := range candidates {
+			var m = make(map[string]any)
+			for _, field := range strings.Split(fieldsParam, ",") {
+				if f, ok := candidate.fields[strings.ToLower(strings.TrimSpace(field))]; ok {
+					m[f.name] = f.value
+				}
+			}
+			projected = append(projected, m)
+		}
+		return projected, nil
+	} else {
+		var result = make([]any, 0, len(candidates))
+		for _, candidate := range candidates {
+			result = append(result, candidate.entity)
+		}
+		return result, nil
+	}
+}
+
+func makeQueryCandidate(v any) (queryCandidate, error) {
+	var decoded map[string]any
+	var decoder = json.NewDecoder(bytes.NewReader(bind.ToJson(v)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return queryCandidate{}, err
+	}
+	var candidate = queryCandidate{entity: v, fields: make(map[string]jsonField, len(decoded))}
+	for name, value := range decoded {
+		candidate.fields[strings.ToLower(name)] = jsonField{name: name, value: value}
+	}
+	return candidate, nil
+}
+
+func (this queryCandidate) matches(params url.Values) bool {
+	for param, patterns := range params {
+		if isReservedParam(param) {
+			continue
+		}
+		if field, ok := this.fields[strings.ToLower(param)]; !ok {
+			return false
+		} else if !slices.ContainsFunc(patterns, func(pattern string) bool { return jsonValueMatches(field.value, pattern) }) {
+			return false
+		}
+	}
+	return true
+}
+
+func isReservedParam(param string) bool {
+	return param == "sort" || param == "limit" || param == "offset" || param == "fields"
+}
+
+func jsonValueMatches(value any, pattern string) bool {
+	switch v := value.(type) {
+	case []any:
+		for _, element := range v {
+			if jsonValueMatches(element, pattern) {
+				return true
+			}
+		}
+		return false
+	case map[string]any:
+		return false
+	default:
+		var matched, _ = path.Match(strings.ToLower(pattern), strings.ToLower(jsonValueAsString(v)))
+		return matched
+	}
+}
+
+func jsonValueAsString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return strings.TrimSpace(string(bind.ToJson(v)))
+	}
+}
+
+// Numbers compare numerically, everything else as strings. Missing values sort last
+func compareJsonValues(v1, v2 any) int {
+	if v1 == nil || v2 == nil {
+		if v1 == nil && v2 == nil {
+			return 0
+		} else if v1 == nil {
+			return 1
+		} else {
+			return -1
+		}
+	}
+	if n1, ok := v1.(json.Number); ok {
+		if n2, ok := v2.(json.Number); ok {
+			var f1, _ = n1.Float64()
+			var f2, _ = n2.Float64()
+			if f1 < f2 {
+				return -1
+			} else if f1 > f2 {
+				return 1
+			} else {
+				return 0
+			}
+		}
+	}
+	return strings.Compare(strings.ToLower(jsonValueAsString(v1)), strings.ToLower(jsonValueAsString(v2)))
+}
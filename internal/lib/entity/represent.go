@@ -0,0 +1,194 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package entity
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/surlykke/refude/pkg/bind"
+)
+
+/*
+* Entities, and lists of entities, may be served as:
+*
+*   application/json            The default
+*   text/html                   Using HtmlRenderer, if set
+*   text/plain                  A table (list) or name/value pairs (single entity), aligned for humans
+*   text/tab-separated-values   Same as text/plain, but tab-separated
+*
+* Tables have the columns given by the 'fields' query parameter, default 'path,title,subtitle'.
+* 'path' is the entity's self link.
+*
+* The ETag is made from the json of the entity (or list), whatever the representation, so a client may use it in an
+* If-Match, having fetched any of them. Being the same for different representations, it is weak.
+ */
+
+// Renders a resource as html. Set by whoever can (the desktop package lives above us)
+var HtmlRenderer func(title string, icon string, data [][]string) ([]byte, error)
+
+var defaultColumns = []string{"path", "title", "subtitle"}
+
+func offeredTypes() []string {
+	var offered = []string{"application/json"}
+	if HtmlRenderer != nil {
+		offered = append(offered, "text/html")
+	}
+	return append(offered, "text/plain", "text/tab-separated-values")
+}
+
+// etag is that of the entity, see entityETag. Given ifNoneMatch matches it, we return 304 Not Modified
+func represent(data any, list bool, columns []string, accept string, etag string, ifNoneMatch string) bind.Response {
+	var contentType = bind.Negotiate(accept, offeredTypes()...)
+	if contentType == "" {
+		return bind.NotAcceptable()
+	} else if ifNoneMatch != "" && bind.ETagMatches(ifNoneMatch, etag) {
+		return bind.NotModified().WithHeader("ETag", etag).WithHeader("Vary", "Accept")
+	}
+
+	var body []byte
+	var err error
+	switch contentType {
+	case "application/json":
+		body = bind.ToJson(data)
+	case "text/html":
+		body, err = toHtml(data, list)
+	default:
+		body, err = toText(data, list, columns, contentType == "text/tab-separated-values")
+	}
+	if err != nil {
+		return bind.ServerError(err)
+	}
+
+	return bind.Response{
+		Status:  http.StatusOK,
+		Headers: http.Header{"Content-Type": {contentType}, "Etag": {etag}, "Vary": {"Accept"}},
+		Body:    body,
+	}
+}
+
+func entityETag(v any) string {
+	return "W/" + bind.ETag(bind.ToJson(v))
+}
+
+func toHtml(data any, list bool) ([]byte, error) {
+	var items, err = decodeItems(data, list)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for _, item := range items {
+		var rows = make([][]string, 0, len(item.fields))
+		for _, name := range slices.Sorted(maps.Keys(item.fields)) {
+			if name != "title" && name != "icon" {
+				rows = append(rows, []string{item.fields[name].name, item.cell(name)})
+			}
+		}
+		if html, err := HtmlRenderer(item.cell("title"), item.cell("icon"), rows); err != nil {
+			return nil, err
+		} else {
+			buf.Write(html)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func toText(data any, list bool, columns []string, tabSeparated bool) ([]byte, error) {
+	var items, err = decodeItems(data, list)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var tw *tabwriter.Writer
+	if !tabSeparated {
+		tw = tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+		w = tw
+	}
+
+	if list {
+		if len(columns) == 0 {
+			columns = defaultColumns
+		}
+		w.Write([]byte(strings.Join(columns, "\t") + "\n"))
+		for _, item := range items {
+			var cells = make([]string, len(columns))
+			for i, column := range columns {
+				cells[i] = item.cell(strings.ToLower(strings.TrimSpace(column)))
+			}
+			w.Write([]byte(strings.Join(cells, "\t") + "\n"))
+		}
+	} else if len(items) > 0 {
+		var item = items[0]
+		w.Write([]byte("path\t" + item.cell("path") + "\n"))
+		for _, name := range slices.Sorted(maps.Keys(item.fields)) {
+			if name != "links" {
+				w.Write([]byte(item.fields[name].name + "\t" + item.cell(name) + "\n"))
+			}
+		}
+	}
+
+	if tw != nil {
+		if err := tw.Flush(); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeItems(data any, list bool) ([]queryCandidate, error) {
+	var values = []any{data}
+	if list {
+		var listVal = reflect.ValueOf(data)
+		if listVal.Kind() != reflect.Slice {
+			return nil, errors.New("not a list")
+		}
+		values = make([]any, listVal.Len())
+		for i := range values {
+			values[i] = listVal.Index(i).Interface()
+		}
+	}
+	var items = make([]queryCandidate, 0, len(values))
+	for _, v := range values {
+		if item, err := makeQueryCandidate(v); err != nil {
+			return nil, err
+		} else {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// A field value as a single line of text. 'path' gives the self link
+func (this queryCandidate) cell(name string) string {
+	var text string
+	if name == "path" {
+		if links, ok := this.fields["links"].value.([]any); ok {
+			for _, link := range links {
+				if l, ok := link.(map[string]any); ok && l["rel"] == Self {
+					text, _ = l["href"].(string)
+				}
+			}
+		}
+	} else if values, ok := this.fields[name].value.([]any); ok {
+		var texts = make([]string, 0, len(values))
+		for _, v := range values {
+			texts = append(texts, jsonValueAsString(v))
+		}
+		text = strings.Join(texts, ",")
+	} else {
+		text = jsonValueAsString(this.fields[name].value)
+	}
+	return strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(text)
+}
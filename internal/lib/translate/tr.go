@@ -9,6 +9,7 @@ package translate
 import (
 	"os"
 	"regexp"
+	"slices"
 )
 
 var lcMessage string
@@ -78,6 +79,11 @@ func Texts(texts []string) []string {
 	return translated
 }
 
+// How well loc matches the current locale: 0 is best, -1 is no match
+func LocalePreference(loc string) int {
+	return slices.Index(lcMatchers, loc)
+}
+
 func LocaleMatch(loc string) bool {
 	for _, lm := range lcMatchers {
 		if loc == lm {
@@ -8,22 +8,31 @@ package translate
 
 var translations = map[string]map[string]string{
 	"da": {
-		"Application":  "Applikation",
-		"Window":       "Vindue",
-		"Tab":          "Fane",
-		"File":         "Fil",
-		"Device":       "Enhed",
-		"Notification": "Notifikation",
-		"Trayitem":     "Tray",
-		"Menu":         "Menu",
-		"Start":        "Start",
-		"Mimetype":     "Mimetype",
-		"Power off":    "Sluk",
-		"Reboot":       "Genstart",
-		"Suspend":      "Slumre",
-		"Power":        "Strømstyring",
-		"Launch":       "Kør",
-		"Open":         "Åbn",
-		"Focus":        "Fokuser",
+		"Application":          "Applikation",
+		"Window":               "Vindue",
+		"Tab":                  "Fane",
+		"File":                 "Fil",
+		"Device":               "Enhed",
+		"Notification":         "Notifikation",
+		"Trayitem":             "Tray",
+		"Menu":                 "Menu",
+		"Start":                "Start",
+		"Mimetype":             "Mimetype",
+		"Power off":            "Sluk",
+		"Reboot":               "Genstart",
+		"Suspend":              "Slumre",
+		"Power":                "Strømstyring",
+		"Launch":               "Kør",
+		"Open":                 "Åbn",
+		"Focus":                "Fokuser",
+		"Close":                "Luk",
+		"Dismiss":              "Afvis",
+		"Copy":                 "Kopier",
+		"Calculation":          "Beregning",
+		"Restore":              "Gendan",
+		"Copy path":            "Kopier sti",
+		"Move to trash":        "Flyt til papirkurv",
+		"Show in file manager": "Vis i filhåndtering",
+		"Delete permanently":   "Slet permanent",
 	},
 }
@@ -9,8 +9,11 @@ import (
 	"bufio"
 	"errors"
 	"log"
+	"math"
 	"os"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/surlykke/refude/internal/lib/translate"
@@ -18,9 +21,13 @@ import (
 
 var commentLine = regexp.MustCompile(`^\s*(#.*)?$`)
 var headerLine = regexp.MustCompile(`^\s*\[(.+?)\]\s*`)
-var keyValueLine = regexp.MustCompile(`^\s*(..+?)(\[(..+)\])?=(.*)`)
+var keyValueLine = regexp.MustCompile(`^\s*([^=\[]+?)\s*(\[(.+)\])?\s*=\s*(.*)`)
 var userDirsLine = regexp.MustCompile(`^\s*(XDG_\w+_DIR)="(.*)"`)
 
+/*
+* IniFile is the simple view of an ini file: Groups, in order, with entries for the current locale, values as given
+* in the file (not unescaped). For more, eg. to edit a file, see IniDocument.
+ */
 type Group struct {
 	Name    string
 	Entries map[string]string
@@ -38,40 +45,56 @@ func (inifile IniFile) FindGroup(groupName string) *Group {
 }
 
 func ReadIniFile(path string) (IniFile, error) {
-	file, err := os.Open(path)
-	if err != nil {
+	if data, err := os.ReadFile(path); err != nil {
 		return nil, err
+	} else {
+		return ParseIniDocument(data).iniFile(path)
 	}
-	defer file.Close()
-	scanner := bufio.NewScanner(file)
+}
 
+func (this *IniDocument) iniFile(path string) (IniFile, error) {
 	var iniFile = make(IniFile, 0)
 	var currentGroup *Group = nil
-	for scanner.Scan() {
-		if commentLine.MatchString(scanner.Text()) {
-			continue
-		} else if m := headerLine.FindStringSubmatch(scanner.Text()); len(m) > 0 {
-			if currentGroup = iniFile.FindGroup(m[1]); currentGroup != nil {
-				log.Print("iniFile", path, " has duplicate group entry: ", m[1])
+	var preference = map[string]int{} // How well the locale of the value we have for a key matches
+	for _, l := range this.lines {
+		switch l.kind {
+		case headerKind:
+			if currentGroup = iniFile.FindGroup(l.group); currentGroup != nil {
+				log.Print("iniFile", path, " has duplicate group entry: ", l.group)
 			} else {
-				currentGroup = &Group{m[1], make(map[string]string)}
+				currentGroup = &Group{l.group, make(map[string]string)}
 				iniFile = append(iniFile, currentGroup)
 			}
-		} else if m = keyValueLine.FindStringSubmatch(scanner.Text()); len(m) > 0 {
+			preference = map[string]int{}
+		case entryKind:
 			if currentGroup == nil {
 				return nil, errors.New("Invalid iniFile," + path + ": file must start with a group heading")
 			}
-			if translate.LocaleMatch(m[3]) || (m[3] == "" && currentGroup.Entries[m[1]] == "") {
-				currentGroup.Entries[m[1]] = m[4]
+			if pref, ok := localePreference(l.locale); ok {
+				if oldPref, seen := preference[l.key]; !seen || pref < oldPref {
+					currentGroup.Entries[l.key] = l.value
+					preference[l.key] = pref
+				}
 			}
-		} else {
-			log.Print(path, ":", scanner.Text(), " - not recognized")
+		case otherKind:
+			log.Print(path, ":", l.raw, " - not recognized")
 		}
 	}
 
 	return iniFile, nil
 }
 
+// Lower is better. An unlocalized value is preferred to nothing, but nothing else
+func localePreference(locale string) (int, bool) {
+	if locale == "" {
+		return math.MaxInt, true
+	} else if pref := translate.LocalePreference(locale); pref > -1 {
+		return pref, true
+	} else {
+		return 0, false
+	}
+}
+
 func GetFromLocalizedMap(m map[string]string) string {
 	var result = ""
 	for loc, val := range m {
@@ -84,21 +107,322 @@ func GetFromLocalizedMap(m map[string]string) string {
 	return result
 }
 
-func WriteIniFile(path string, iniFile IniFile) error {
-	if file, err := os.Create(path); err != nil {
+/*
+* IniDocument holds an ini file (desktop entry, mimeapps.list and the like) line by line, so that it can be edited and
+* written back with everything else as it was: comments, ordering, all locale variants of keys. Unmodified, it's
+* written back byte for byte.
+*
+* Values are unescaped when read and escaped when set, as per the desktop entry spec: \s, \n, \t, \r and \\ and, in
+* lists, \; for a semicolon within an element. List elements are separated (and terminated) by ';'.
+ */
+type IniDocument struct {
+	lines []iniLine
+}
+
+const (
+	blankKind uint8 = iota // Blank lines and comments
+	headerKind
+	entryKind
+	otherKind // Not recognized, kept as is
+)
+
+type iniLine struct {
+	raw    string
+	kind   uint8
+	group  string // headerKind
+	key    string // entryKind
+	locale string
+	value  string // As in the file, ie. escaped
+}
+
+func parseLine(raw string) iniLine {
+	var text = strings.TrimSuffix(raw, "\r")
+	if commentLine.MatchString(text) {
+		return iniLine{raw: raw, kind: blankKind}
+	} else if m := headerLine.FindStringSubmatch(text); len(m) > 0 {
+		return iniLine{raw: raw, kind: headerKind, group: m[1]}
+	} else if m = keyValueLine.FindStringSubmatch(text); len(m) > 0 {
+		return iniLine{raw: raw, kind: entryKind, key: m[1], locale: m[3], value: m[4]}
+	} else {
+		return iniLine{raw: raw, kind: otherKind}
+	}
+}
+
+func ParseIniDocument(data []byte) *IniDocument {
+	var doc = &IniDocument{}
+	for _, raw := range strings.Split(string(data), "\n") {
+		doc.lines = append(doc.lines, parseLine(raw))
+	}
+	return doc
+}
+
+// A file that does not exist gives an empty document
+func ReadIniDocument(path string) (*IniDocument, error) {
+	if data, err := os.ReadFile(path); errors.Is(err, os.ErrNotExist) {
+		return &IniDocument{}, nil
+	} else if err != nil {
+		return nil, err
+	} else {
+		return ParseIniDocument(data), nil
+	}
+}
+
+func (this *IniDocument) Bytes() []byte {
+	var raws = make([]string, len(this.lines))
+	for i, l := range this.lines {
+		raws[i] = l.raw
+	}
+	return []byte(strings.Join(raws, "\n"))
+}
+
+// Via a temporary file, so readers never see it half written. If path is a symlink (eg. into a dotfiles
+// repository), the file it points to is written, and an existing file keeps its mode
+func (this *IniDocument) Write(path string) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	var mode os.FileMode = 0644
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	var tmpPath = path + ".refude-tmp"
+	if err := os.WriteFile(tmpPath, this.Bytes(), mode); err != nil {
+		return err
+	} else if err := os.Chmod(tmpPath, mode); err != nil { // WriteFile's mode is subject to umask
+		os.Remove(tmpPath)
+		return err
+	} else if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
 		return err
+	}
+	return nil
+}
+
+func (this *IniDocument) Groups() []string {
+	var groups = []string{}
+	for _, l := range this.lines {
+		if l.kind == headerKind {
+			groups = append(groups, l.group)
+		}
+	}
+	return groups
+}
+
+// Keys of group, in order, each once however many locale variants it has
+func (this *IniDocument) Keys(group string) []string {
+	var keys = []string{}
+	var start, end = this.findGroup(group)
+	for i := start + 1; start > -1 && i < end; i++ {
+		if l := this.lines[i]; l.kind == entryKind && !slices.Contains(keys, l.key) {
+			keys = append(keys, l.key)
+		}
+	}
+	return keys
+}
+
+// The value given for key with locale (empty for the unlocalized one)
+func (this *IniDocument) Get(group string, key string, locale string) (string, bool) {
+	if i := this.findEntry(group, key, locale); i > -1 {
+		return unescape(this.lines[i].value, false), true
 	} else {
-		defer file.Close()
-		for _, group := range iniFile {
-			file.WriteString("[" + group.Name + "]\n")
-			for key, value := range group.Entries {
-				file.WriteString(key + "=" + value + "\n")
-			}
+		return "", false
+	}
+}
+
+// All variants of key, by locale (empty for the unlocalized one)
+func (this *IniDocument) GetAll(group string, key string) map[string]string {
+	var result = map[string]string{}
+	var start, end = this.findGroup(group)
+	for i := start + 1; start > -1 && i < end; i++ {
+		if l := this.lines[i]; l.kind == entryKind && l.key == key {
+			result[l.locale] = unescape(l.value, false)
+		}
+	}
+	return result
+}
+
+// The value of key best matching the current locale
+func (this *IniDocument) GetLocalized(group string, key string) (string, bool) {
+	var value, bestPref, found = "", 0, false
+	for locale, v := range this.GetAll(group, key) {
+		if pref, ok := localePreference(locale); ok && (!found || pref < bestPref) {
+			value, bestPref, found = v, pref, true
 		}
+	}
+	return value, found
+}
+
+func (this *IniDocument) GetList(group string, key string, locale string) []string {
+	if i := this.findEntry(group, key, locale); i > -1 {
+		return splitList(this.lines[i].value)
+	} else {
 		return nil
 	}
 }
 
+/*
+* Sets key (with locale, if given) in group. An existing entry is changed in place, otherwise the entry is added
+* after the last entry of the group, and the group, if not there, added at the end.
+ */
+func (this *IniDocument) Set(group string, key string, locale string, value string) {
+	this.set(group, key, locale, escape(value, false))
+}
+
+func (this *IniDocument) SetList(group string, key string, locale string, values []string) {
+	var escaped strings.Builder
+	for _, v := range values {
+		escaped.WriteString(escape(v, true))
+		escaped.WriteString(";")
+	}
+	this.set(group, key, locale, escaped.String())
+}
+
+func (this *IniDocument) Delete(group string, key string, locale string) {
+	if i := this.findEntry(group, key, locale); i > -1 {
+		this.lines = slices.Delete(this.lines, i, i+1)
+	}
+}
+
+func (this *IniDocument) set(group string, key string, locale string, escapedValue string) {
+	var raw = key
+	if locale != "" {
+		raw = raw + "[" + locale + "]"
+	}
+	raw = raw + "=" + escapedValue
+	var newLine = iniLine{raw: raw, kind: entryKind, key: key, locale: locale, value: escapedValue}
+
+	if i := this.findEntry(group, key, locale); i > -1 {
+		if this.lines[i].value != escapedValue {
+			this.lines[i] = newLine
+		}
+	} else if start, end := this.findGroup(group); start > -1 {
+		var pos = end
+		for pos > start+1 && this.lines[pos-1].kind != entryKind {
+			pos--
+		}
+		this.lines = slices.Insert(this.lines, pos, newLine)
+	} else {
+		// Keep a final newline final
+		var trailing []iniLine
+		if n := len(this.lines); n > 0 && this.lines[n-1].raw == "" {
+			this.lines, trailing = this.lines[:n-1], this.lines[n-1:]
+		}
+		if n := len(this.lines); n > 0 && strings.TrimSpace(this.lines[n-1].raw) != "" {
+			this.lines = append(this.lines, iniLine{kind: blankKind})
+		}
+		this.lines = append(this.lines, iniLine{raw: "[" + group + "]", kind: headerKind, group: group}, newLine)
+		if trailing == nil {
+			trailing = []iniLine{{kind: blankKind}}
+		}
+		this.lines = append(this.lines, trailing...)
+	}
+}
+
+// Returns the index of the group's header line (-1 if not there), and of the line after the group
+func (this *IniDocument) findGroup(group string) (int, int) {
+	var start = -1
+	for i, l := range this.lines {
+		if l.kind == headerKind {
+			if start > -1 {
+				return start, i
+			} else if l.group == group {
+				start = i
+			}
+		}
+	}
+	return start, len(this.lines)
+}
+
+func (this *IniDocument) findEntry(group string, key string, locale string) int {
+	var start, end = this.findGroup(group)
+	for i := start + 1; start > -1 && i < end; i++ {
+		if l := this.lines[i]; l.kind == entryKind && l.key == key && l.locale == locale {
+			return i
+		}
+	}
+	return -1
+}
+
+// Escapes we don't know are left as they are
+func unescape(value string, inList bool) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i+1 >= len(value) {
+			b.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 's':
+			b.WriteByte(' ')
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case '\\':
+			b.WriteByte('\\')
+		case ';':
+			if inList {
+				b.WriteByte(';')
+			} else {
+				b.WriteString(`\;`)
+			}
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
+
+func escape(value string, inList bool) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == '\\':
+			b.WriteString(`\\`)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == ' ' && i == 0: // Leading space would be taken as separating key and value
+			b.WriteString(`\s`)
+		case c == ';' && inList:
+			b.WriteString(`\;`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// Elements are separated by unescaped ';'. Empty elements are dropped
+func splitList(value string) []string {
+	var result = []string{}
+	var start = 0
+	for i := 0; i <= len(value); i++ {
+		if i == len(value) || value[i] == ';' {
+			if elem := strings.TrimSpace(unescape(value[start:i], true)); elem != "" {
+				result = append(result, elem)
+			}
+			start = i + 1
+		} else if value[i] == '\\' {
+			i++
+		}
+	}
+	return result
+}
+
 func readUserDirs(home string, configHome string) (map[string]string, error) {
 	var res = map[string]string{}
 	var file, err = os.Open(configHome + "/user-dirs.dirs")
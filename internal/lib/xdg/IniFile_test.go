@@ -0,0 +1,209 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package xdg
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const desktopEntry = `# A comment before any group
+[Desktop Entry]
+Name=Text Editor
+Name[da]=Teksteditor
+Name[de]=Texteditor
+Name[sr@latin]=Uređivač teksta
+Comment=Edit text files
+Comment[da]=Redigér tekstfiler
+# Keywords are a list
+Keywords=Text;Editor;Plaintext;Write;
+Exec=gedit %U
+Icon = org.gnome.gedit
+Terminal=false
+Type=Application
+MimeType=text/plain;application/x-zerosize;
+Categories=GNOME;GTK;Utility;TextEditor;
+X-Escaped=a\sb\nc\\d
+X-List=one\;two;three;
+Actions=new-window;
+this line is not understood
+
+[Desktop Action new-window]
+Name=New Window
+Name[da]=Nyt vindue
+Exec=gedit --new-window
+`
+
+const mimeappsList = `[Default Applications]
+text/plain=org.gnome.gedit.desktop;
+image/png=org.gnome.eog.desktop;gimp.desktop;
+
+[Added Associations]
+# Hand edited
+application/pdf=org.gnome.Evince.desktop;
+`
+
+func TestRoundTrip(t *testing.T) {
+	for _, sample := range []string{
+		desktopEntry,
+		mimeappsList,
+		strings.TrimSuffix(mimeappsList, "\n"), // No final newline
+		strings.ReplaceAll(mimeappsList, "\n", "\r\n"),
+		"",
+		"\n\n# Only comments\n",
+	} {
+		if out := string(ParseIniDocument([]byte(sample)).Bytes()); out != sample {
+			t.Errorf("round trip changed\n%q\ninto\n%q", sample, out)
+		}
+	}
+}
+
+// Sets one key and checks that only its line changed
+func TestEditChangesOneLine(t *testing.T) {
+	var tests = []struct {
+		sample string
+		edit   func(doc *IniDocument)
+		line   string // The line expected, in place of the one of the key
+	}{
+		{desktopEntry, func(doc *IniDocument) { doc.Set("Desktop Entry", "Name", "de", "Neuer Texteditor") }, "Name[de]=Neuer Texteditor"},
+		{desktopEntry, func(doc *IniDocument) { doc.Set("Desktop Entry", "Name", "", "Editor") }, "Name=Editor"},
+		{desktopEntry, func(doc *IniDocument) { doc.Set("Desktop Entry", "Icon", "", "accessories-text-editor") }, "Icon=accessories-text-editor"},
+		{desktopEntry, func(doc *IniDocument) { doc.Set("Desktop Entry", "X-Escaped", "", " x\ty") }, `X-Escaped=\sx\ty`},
+		{desktopEntry, func(doc *IniDocument) { doc.SetList("Desktop Entry", "X-List", "", []string{"a;b", "c"}) }, `X-List=a\;b;c;`},
+		{desktopEntry, func(doc *IniDocument) { doc.Set("Desktop Action new-window", "Name", "", "Another Window") }, "Name=Another Window"},
+		{mimeappsList, func(doc *IniDocument) {
+			doc.SetList("Default Applications", "image/png", "", []string{"gimp.desktop", "org.gnome.eog.desktop"})
+		}, "image/png=gimp.desktop;org.gnome.eog.desktop;"},
+	}
+	for _, test := range tests {
+		var doc = ParseIniDocument([]byte(test.sample))
+		test.edit(doc)
+		var before, after = strings.Split(test.sample, "\n"), strings.Split(string(doc.Bytes()), "\n")
+		if len(before) != len(after) {
+			t.Errorf("%s: went from %d to %d lines", test.line, len(before), len(after))
+			continue
+		}
+		var changed = []string{}
+		for i := range before {
+			if before[i] != after[i] {
+				changed = append(changed, after[i])
+			}
+		}
+		if !slices.Equal(changed, []string{test.line}) {
+			t.Errorf("%s: changed lines: %q", test.line, changed)
+		}
+	}
+}
+
+func TestSetUnchangedValue(t *testing.T) {
+	var doc = ParseIniDocument([]byte(desktopEntry))
+	doc.Set("Desktop Entry", "Icon", "", "org.gnome.gedit") // Same value, though the line is spaced differently
+	doc.SetList("Desktop Entry", "Keywords", "", []string{"Text", "Editor", "Plaintext", "Write"})
+	if string(doc.Bytes()) != desktopEntry {
+		t.Errorf("setting values already there changed the document:\n%s", doc.Bytes())
+	}
+}
+
+func TestAddAndDelete(t *testing.T) {
+	var doc = ParseIniDocument([]byte(mimeappsList))
+	doc.SetList("Default Applications", "text/html", "", []string{"firefox.desktop"})
+	doc.SetList("Removed Associations", "text/plain", "", []string{"vim.desktop"})
+	var expected = `[Default Applications]
+text/plain=org.gnome.gedit.desktop;
+image/png=org.gnome.eog.desktop;gimp.desktop;
+text/html=firefox.desktop;
+
+[Added Associations]
+# Hand edited
+application/pdf=org.gnome.Evince.desktop;
+
+[Removed Associations]
+text/plain=vim.desktop;
+`
+	if string(doc.Bytes()) != expected {
+		t.Errorf("got\n%s\nwant\n%s", doc.Bytes(), expected)
+	}
+
+	doc.Delete("Default Applications", "text/html", "")
+	doc.Delete("Removed Associations", "text/plain", "")
+	doc.Delete("Added Associations", "no/such-type", "")
+	expected = mimeappsList + "\n[Removed Associations]\n"
+	if string(doc.Bytes()) != expected {
+		t.Errorf("got\n%s\nwant\n%s", doc.Bytes(), expected)
+	}
+}
+
+func TestGet(t *testing.T) {
+	var doc = ParseIniDocument([]byte(desktopEntry))
+	var gets = []struct {
+		group, key, locale string
+		expected           string
+	}{
+		{"Desktop Entry", "Name", "", "Text Editor"},
+		{"Desktop Entry", "Name", "sr@latin", "Uređivač teksta"},
+		{"Desktop Entry", "Icon", "", "org.gnome.gedit"},
+		{"Desktop Entry", "X-Escaped", "", "a b\nc\\d"},
+		{"Desktop Action new-window", "Exec", "", "gedit --new-window"},
+	}
+	for _, g := range gets {
+		if value, ok := doc.Get(g.group, g.key, g.locale); !ok || value != g.expected {
+			t.Errorf("Get(%s, %s, %s): got %q, %t", g.group, g.key, g.locale, value, ok)
+		}
+	}
+	if _, ok := doc.Get("Desktop Entry", "Name", "fr"); ok {
+		t.Error("Got Name[fr], which isn't there")
+	}
+	if list := doc.GetList("Desktop Entry", "X-List", ""); !slices.Equal(list, []string{"one;two", "three"}) {
+		t.Errorf("GetList: got %q", list)
+	}
+	if all := doc.GetAll("Desktop Entry", "Comment"); len(all) != 2 || all[""] != "Edit text files" || all["da"] != "Redigér tekstfiler" {
+		t.Errorf("GetAll: got %v", all)
+	}
+	if groups := doc.Groups(); !slices.Equal(groups, []string{"Desktop Entry", "Desktop Action new-window"}) {
+		t.Errorf("Groups: got %q", groups)
+	}
+	if keys := doc.Keys("Desktop Action new-window"); !slices.Equal(keys, []string{"Name", "Exec"}) {
+		t.Errorf("Keys: got %q", keys)
+	}
+}
+
+func TestWriteThroughSymlink(t *testing.T) {
+	var dir = t.TempDir()
+	var target, link = filepath.Join(dir, "dotfiles-mimeapps.list"), filepath.Join(dir, "mimeapps.list")
+	if err := os.WriteFile(target, []byte(mimeappsList), 0600); err != nil {
+		t.Fatal(err)
+	} else if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc = ParseIniDocument([]byte(mimeappsList))
+	doc.SetList("Default Applications", "text/html", "", []string{"firefox.desktop"})
+	if err := doc.Write(link); err != nil {
+		t.Fatal(err)
+	}
+
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("link replaced: %v, %v", info, err)
+	}
+	if info, err := os.Stat(target); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("target mode changed: %v, %v", info, err)
+	}
+	if bytes, err := os.ReadFile(target); err != nil || string(bytes) != string(doc.Bytes()) {
+		t.Errorf("target not written: %s, %v", bytes, err)
+	}
+}
+
+func TestWriteNewFile(t *testing.T) {
+	var path = filepath.Join(t.TempDir(), "sub", "mimeapps.list")
+	if err := ParseIniDocument([]byte(mimeappsList)).Write(path); err != nil {
+		t.Fatal(err)
+	} else if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("got %v, %v", info, err)
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package xdg
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+/*
+* For state and caches kept as json, eg. under StateHome or CacheHome.
+ */
+
+// Unmarshals the json in path into v. A missing file is not an error, v is then left as it is
+func ReadJsonFile(path string, v any) error {
+	if bytes, err := os.ReadFile(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	} else {
+		return json.Unmarshal(bytes, v)
+	}
+}
+
+// Writes v as json to path, readable only by the user. By way of a temporary file, so a crash doesn't leave path
+// half written. The directory of path is created if need be.
+func WriteJsonFile(path string, v any) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	} else if bytes, err := json.Marshal(v); err != nil {
+		return err
+	} else if err := os.WriteFile(path+".tmp", bytes, 0600); err != nil {
+		return err
+	} else {
+		return os.Rename(path+".tmp", path)
+	}
+}
@@ -6,6 +6,8 @@
 package xdg
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"os/exec"
 	"path"
@@ -20,6 +22,7 @@ var Home string
 var ConfigHome string
 var ConfigDirs []string
 var CacheHome string
+var StateHome string
 var DataHome string
 var DataDirs []string
 var IconBasedirs []string
@@ -39,12 +42,15 @@ var PicturesDir string
 var VideosDir string
 
 var NmSocketPath string
+var RefudeSocketPath string
+var RefudeTokenPath string
 
 func init() {
 	Home = clean(os.Getenv("HOME"))
 	ConfigHome = clean(coalesce(os.Getenv("XDG_CONFIG_HOME"), Home+"/.config"))
 	ConfigDirs = cleanS(utils.Split(coalesce(os.Getenv("XDG_CONFIG_DIRS"), "/etc/xdg"), ":"))
 	CacheHome = clean(coalesce(os.Getenv("XDG_CACHE_HOME"), Home+"/.cache"))
+	StateHome = clean(coalesce(os.Getenv("XDG_STATE_HOME"), Home+"/.local/state"))
 	DataHome = clean(coalesce(os.Getenv("XDG_DATA_HOME"), Home+"/.local/share"))
 	DataDirs = cleanS(utils.Split(coalesce(os.Getenv("XDG_DATA_DIRS"), "/usr/local/share:/usr/share"), ":"))
 	DataDirs = slices.DeleteFunc(DataDirs, func(s string) bool { return s == DataHome })
@@ -55,7 +61,12 @@ func init() {
 	}
 	PixmapDir = "/usr/share/pixmaps"
 
-	RuntimeDir = clean(coalesce(os.Getenv("XDG_RUNTIME_DIR"), "/tmp"))
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		RuntimeDir = clean(runtimeDir)
+	} else {
+		// Not /tmp itself, where other users could take our socket and token paths. See CheckRuntimeDir
+		RuntimeDir = fmt.Sprintf("/tmp/refude-%d", os.Getuid())
+	}
 	CurrentDesktop = utils.Split(coalesce(os.Getenv("XDG_CURRENT_DESKTOP"), ""), ":")
 	Locale = coalesce(os.Getenv("LANG"), "") // TODO Look at other env variables too
 
@@ -78,22 +89,50 @@ func init() {
 	VideosDir = clean(coalesce(userDirs["XDG_VIDEOS_DIR"], Home+"/Videos"))
 
 	NmSocketPath = RuntimeDir + "/org.refude.nm-socket"
+	RefudeSocketPath = RuntimeDir + "/refude.sock"
+	RefudeTokenPath = RuntimeDir + "/refude.token"
+}
+
+/*
+* Ensures RuntimeDir exists and is private: a directory (not a symlink), owned by us, with mode 0700. Without
+* XDG_RUNTIME_DIR we create one under /tmp, and if someone else got there first, we refuse to use it.
+ */
+func CheckRuntimeDir() error {
+	if err := os.Mkdir(RuntimeDir, 0700); err != nil && !errors.Is(err, os.ErrExist) {
+		return err
+	} else if info, err := os.Lstat(RuntimeDir); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", RuntimeDir)
+	} else if stat, ok := info.Sys().(*syscall.Stat_t); !ok || int(stat.Uid) != os.Getuid() {
+		return fmt.Errorf("%s is not owned by us", RuntimeDir)
+	} else if info.Mode().Perm() != 0700 {
+		return fmt.Errorf("%s has mode %o, should be 0700", RuntimeDir, info.Mode().Perm())
+	} else {
+		return nil
+	}
 }
 
 func RunCmd(argv ...string) error {
+	var _, err = StartCmd(Home, argv...)
+	return err
+}
+
+// Like RunCmd, with dir as working directory. Returns the pid of the started process
+func StartCmd(dir string, argv ...string) (int, error) {
 	var cmd = exec.Command(argv[0], argv[1:]...)
 
 	os.Unsetenv("LD_PRELOAD") // We don't want this passed on to launced apps
-	cmd.Dir = Home
+	cmd.Dir = dir
 	cmd.Stdout = nil
 	cmd.Stderr = nil
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true} // So ctrl-C against RefudeDesktopService doesn't affect
 
 	if err := cmd.Start(); err == nil {
 		go cmd.Wait()
-		return nil
+		return cmd.Process.Pid, nil
 	} else {
-		return err
+		return 0, err
 	}
 }
 
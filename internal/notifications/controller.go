@@ -193,6 +193,7 @@ func Notify(
 	for i := 0; i+1 < len(actions); i = i + 2 {
 		notification.NActions[actions[i]] = actions[i+1]
 	}
+	notification.AddDeleteAction("Dismiss", "")
 
 	for name, val := range hints {
 		if name == "urgency" {
@@ -224,7 +225,7 @@ func Notify(
 	notification.Expires = time.Now().Add(time.Duration(expire_timeout) * time.Millisecond)
 
 	NotificationMap.Put(id, &notification)
-	watch.Publish("resourceChanged", "/flash")
+	watch.ResourceChanged("/flash", watch.Updated)
 	watch.Publish("search", "")
 	sendNotificationsToGui()
 
@@ -40,6 +40,10 @@ func (u Urgency) MarshalJSON() ([]byte, error) {
 	}
 }
 
+func (u Urgency) JSONSchema() map[string]any {
+	return map[string]any{"type": "string", "enum": []string{"low", "normal", "critical"}}
+}
+
 type UnixTime time.Time // Behaves like Time, but json-marshalls to milliseconds since epoch
 
 func (ut UnixTime) MarshalJSON() ([]byte, error) {
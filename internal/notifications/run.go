@@ -9,18 +9,24 @@ import (
 	"github.com/surlykke/refude/internal/icons"
 	"github.com/surlykke/refude/internal/lib/entity"
 	"github.com/surlykke/refude/internal/notifygui"
+	"github.com/surlykke/refude/internal/search"
 	"github.com/surlykke/refude/internal/watch"
 )
 
 var NotificationMap = entity.MakeMap[uint32, *Notification]()
 
+func init() {
+	search.Register(search.MapProvider("notifications", 0, 0, NotificationMap))
+}
+
 func removeNotification(id uint32, reason uint32) {
 	if n, ok := NotificationMap.Get(id); ok && !n.Deleted {
 		var copy = *n
 		copy.Deleted = true
+		copy.Meta.DeleteAction = nil
 		NotificationMap.Put(id, &copy)
 		conn.Emit(NOTIFICATIONS_PATH, NOTIFICATIONS_INTERFACE+".NotificationClosed", id, reason)
-		watch.Publish("resourceChanged", "/flash")
+		watch.ResourceChanged("/flash", watch.Updated)
 		sendNotificationsToGui()
 		watch.Publish("search", "")
 	}
@@ -6,11 +6,16 @@
 //
 package options
 
-import "github.com/jessevdk/go-flags"
+import (
+	"github.com/jessevdk/go-flags"
+	"github.com/surlykke/refude/internal/lib/xdg"
+)
 
 type Options struct {
 	NoNotifications bool            `long:"no-notifications" description:"Omit notification functionality"`
 	IgnoreWinAppIds map[string]bool `long:"ignore-window" description:"Omit windows with these app-ids from search"`
+	Listen          []string        `long:"listen" description:"Address to listen on, either <host>:<port> or unix:<path>. May be given more than once. Defaults to unix:$XDG_RUNTIME_DIR/refude.sock and 127.0.0.1:7938. Ignored when socket activated"`
+	AllowOrigins    []string        `long:"allow-origin" description:"Origin, other than refude-server's own, allowed to make requests. Eg. chrome-extension://<extension id>. May be given more than once"`
 }
 
 func GetOpts() Options {
@@ -18,5 +23,8 @@ func GetOpts() Options {
 	if _, err := flags.Parse(&opts); err != nil {
 		panic(err)
 	}
+	if len(opts.Listen) == 0 {
+		opts.Listen = []string{"unix:" + xdg.RefudeSocketPath, "127.0.0.1:7938"}
+	}
 	return opts
 }
@@ -11,10 +11,15 @@ import (
 	"github.com/godbus/dbus/v5"
 
 	"github.com/surlykke/refude/internal/lib/entity"
+	"github.com/surlykke/refude/internal/search"
 )
 
 var DeviceMap = entity.MakeMap[string, *Device]()
 
+func init() {
+	search.Register(search.MapProvider("devices", 3, 0, DeviceMap))
+}
+
 func Run() {
 	var signals = subscribe()
 
@@ -0,0 +1,239 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package recent
+
+import (
+	"encoding/xml"
+	"log"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/surlykke/refude/internal/applications"
+	"github.com/surlykke/refude/internal/lib/entity"
+	"github.com/surlykke/refude/internal/lib/xdg"
+	"github.com/surlykke/refude/internal/search"
+	"github.com/surlykke/refude/pkg/bind"
+)
+
+/*
+* Recently used files, as recorded by Gtk (and others) in $XDG_DATA_HOME/recently-used.xbel.
+* Only local files that still exist are included.
+ */
+
+type RecentFile struct {
+	entity.Base
+	OsPath       string
+	Mimetype     string
+	Added        time.Time
+	Modified     time.Time
+	Visited      time.Time
+	Applications []string // Desktop ids of the apps that used the file, most recent first
+}
+
+var RecentMap = entity.MakeMap[string, *RecentFile]()
+
+var xbelPath = xdg.DataHome + "/recently-used.xbel"
+
+func init() {
+	search.Register(recentProvider{search.MapProvider("recent", 3, 0, RecentMap)})
+}
+
+func (this *RecentFile) DoPost(action string) bind.Response {
+	if action == "" && len(this.Meta.Actions) > 0 {
+		action = this.Meta.Actions[0].Id
+	}
+	if applications.OpenFile(action, this.OsPath) {
+		return bind.Accepted()
+	} else {
+		return bind.NotFound()
+	}
+}
+
+// Recently used files rank better, the more recently used
+type recentProvider struct {
+	search.Provider
+}
+
+const maxBonus = 30
+const bonusHalfLife = 3 * 24 * time.Hour
+
+func (this recentProvider) Adjust(b entity.Base, rank uint) uint {
+	if rf, ok := RecentMap.Get(strings.TrimPrefix(b.Meta.Path, RecentMap.GetPrefix())); ok {
+		var age = time.Since(rf.lastUsed())
+		var bonus = uint(maxBonus * math.Pow(0.5, float64(age)/float64(bonusHalfLife)))
+		return rank - min(rank, bonus)
+	}
+	return rank
+}
+
+func (this *RecentFile) lastUsed() time.Time {
+	return maxTime(this.Added, this.Modified, this.Visited)
+}
+
+func maxTime(times ...time.Time) time.Time {
+	var result time.Time
+	for _, t := range times {
+		if t.After(result) {
+			result = t
+		}
+	}
+	return result
+}
+
+func Run() {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		panic(err)
+	}
+	// The file is typically replaced, not written to, so we watch its directory
+	if err := watcher.Add(filepath.Dir(xbelPath)); err != nil {
+		log.Print("Not watching ", xbelPath, ": ", err)
+	}
+
+	var appSubscription = applications.AppEvents.Subscribe()
+	var appEvents = make(chan struct{})
+	go func() {
+		for {
+			appEvents <- appSubscription.Next()
+		}
+	}()
+
+	load()
+	for {
+		select {
+		case ev := <-watcher.Events:
+			if ev.Name == xbelPath {
+				load()
+			}
+		case <-appEvents:
+			load() // For the actions
+		}
+	}
+}
+
+type xbel struct {
+	Bookmarks []xbelBookmark `xml:"bookmark"`
+}
+
+type xbelBookmark struct {
+	Href         string            `xml:"href,attr"`
+	Added        string            `xml:"added,attr"`
+	Modified     string            `xml:"modified,attr"`
+	Visited      string            `xml:"visited,attr"`
+	Mimetype     xbelMimetype      `xml:"info>metadata>mime-type"`
+	Applications []xbelApplication `xml:"info>metadata>applications>application"`
+}
+
+type xbelMimetype struct {
+	Type string `xml:"type,attr"`
+}
+
+type xbelApplication struct {
+	Name     string `xml:"name,attr"`
+	Exec     string `xml:"exec,attr"`
+	Modified string `xml:"modified,attr"`
+}
+
+func load() {
+	var bytes, err = os.ReadFile(xbelPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Print("Could not read ", xbelPath, ": ", err)
+		}
+		RecentMap.ReplaceAll(map[string]*RecentFile{})
+		return
+	}
+	var x xbel
+	if err := xml.Unmarshal(bytes, &x); err != nil {
+		log.Print("Could not parse ", xbelPath, ": ", err)
+		return
+	}
+
+	var recentFiles = make(map[string]*RecentFile, len(x.Bookmarks))
+	for _, bm := range x.Bookmarks {
+		if rf := makeRecentFile(bm); rf != nil {
+			recentFiles[rf.OsPath[1:]] = rf
+		}
+	}
+	RecentMap.ReplaceAll(recentFiles)
+}
+
+func makeRecentFile(bm xbelBookmark) *RecentFile {
+	var u, err = url.Parse(bm.Href)
+	if err != nil || u.Scheme != "file" {
+		return nil
+	}
+	var osPath = filepath.Clean(u.Path)
+	if _, err := os.Stat(osPath); err != nil {
+		return nil
+	}
+
+	var rf = &RecentFile{
+		Base:     *entity.MakeBase(filepath.Base(osPath), osPath, strings.ReplaceAll(bm.Mimetype.Type, "/", "-"), "Recent file"),
+		OsPath:   osPath,
+		Mimetype: bm.Mimetype.Type,
+		Added:    parseTime(bm.Added),
+		Modified: parseTime(bm.Modified),
+		Visited:  parseTime(bm.Visited),
+	}
+
+	slices.SortFunc(bm.Applications, func(a1, a2 xbelApplication) int {
+		return parseTime(a2.Modified).Compare(parseTime(a1.Modified))
+	})
+	for _, app := range bm.Applications {
+		if da, ok := findApp(app); ok && !slices.Contains(rf.Applications, da.DesktopId) {
+			rf.Applications = append(rf.Applications, da.DesktopId)
+		}
+	}
+	for _, da := range applications.GetHandlers(rf.Mimetype) {
+		if !slices.Contains(rf.Applications, da.DesktopId) {
+			rf.Applications = append(rf.Applications, da.DesktopId)
+		}
+	}
+	for _, appId := range rf.Applications {
+		if title, icon, ok := applications.GetTitleAndIcon(appId); ok {
+			rf.AddAction(appId, title, icon)
+		}
+	}
+	return rf
+}
+
+func parseTime(s string) time.Time {
+	var t, _ = time.Parse(time.RFC3339Nano, s)
+	return t
+}
+
+/*
+* The xbel file names applications by whatever the recording app chose - often the program name, sometimes
+* the desktop id or the application's (untranslated) name - and gives an exec line. We try these in turn.
+ */
+func findApp(app xbelApplication) (*applications.DesktopApplication, bool) {
+	if da, ok := applications.AppMap.Get(strings.TrimSuffix(app.Name, ".desktop")); ok {
+		return da, true
+	}
+	var program = execProgram(app.Exec)
+	for _, da := range applications.AppMap.GetAll() {
+		if da.Title == app.Name || (program != "" && execProgram(da.Exec) == program) {
+			return da, true
+		}
+	}
+	return nil, false
+}
+
+// The program of an exec line, without path. Eg. 'evince' from "'/usr/bin/evince %u'"
+func execProgram(exec string) string {
+	var fields = strings.Fields(strings.Trim(exec, "'\""))
+	if len(fields) == 0 {
+		return ""
+	}
+	return filepath.Base(strings.Trim(fields[0], "'\""))
+}
@@ -0,0 +1,206 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package search
+
+import (
+	"log"
+	"math"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/surlykke/refude/internal/lib/xdg"
+	"github.com/surlykke/refude/pkg/bind"
+)
+
+/*
+* We remember activations (successful posts) of resources, and let resources that are activated often, and recently,
+* rank higher. Also, if a resource has been activated after searching with a term, it ranks higher when the user
+* types that term (or a prefix of it) again.
+*
+* For each path we keep a score, which is incremented on activation and halves every week.
+*
+* The store is written to disk a little while after it changes, so a burst of activations is written once.
+ */
+
+const halfLife = 7 * 24 * time.Hour
+const maxTermsPerPath = 20
+const maxPaths = 1000
+const saveDelay = 5 * time.Second
+
+// Max amount learned data can improve a rank. Results never activated have this added to their rank.
+const maxBonus = 100
+
+type Activations struct {
+	Score float64         `json:"score"`
+	Last  time.Time       `json:"last"`
+	Terms map[string]uint `json:"terms"`
+}
+
+var activations map[string]*Activations
+var activationsLock sync.Mutex
+var frecencyPath = xdg.StateHome + "/refude/frecency.json"
+var saveTimer *time.Timer
+
+// term is what the user searched with, and may be percent-encoded, as http headers can't carry all of unicode
+func RecordActivation(path string, term string) {
+	if unescaped, err := url.PathUnescape(term); err == nil {
+		term = unescaped
+	}
+	term = strings.ToLower(ParseQuery(term).Term)
+
+	activationsLock.Lock()
+	defer activationsLock.Unlock()
+	load()
+	var now = time.Now()
+	var a, ok = activations[path]
+	if !ok {
+		a = &Activations{Terms: map[string]uint{}}
+		activations[path] = a
+	}
+	a.Score = a.scoreAt(now) + 1
+	a.Last = now
+	if term != "" {
+		a.Terms[term]++
+		if len(a.Terms) > maxTermsPerPath {
+			delete(a.Terms, leastUsed(a.Terms))
+		}
+	}
+	prune(now)
+	scheduleSave()
+}
+
+func (this *Activations) scoreAt(t time.Time) float64 {
+	return this.Score * math.Pow(0.5, float64(t.Sub(this.Last))/float64(halfLife))
+}
+
+// Returns what is to be added to the matcher's rank of a resource, given its path, when searching for term. The
+// penalties are worked out once, up front, so a search takes the lock once, not once per result
+func penalties(term string) func(path string) uint {
+	activationsLock.Lock()
+	defer activationsLock.Unlock()
+	load()
+	var now = time.Now()
+	term = strings.ToLower(term)
+	var result = make(map[string]uint, len(activations))
+	for path, a := range activations {
+		result[path] = a.penalty(term, now)
+	}
+	return func(path string) uint {
+		if p, ok := result[path]; ok {
+			return p
+		} else {
+			return maxBonus
+		}
+	}
+}
+
+// term must be lowercase
+func (this *Activations) penalty(term string, now time.Time) uint {
+	var bonus = 10 * math.Log2(1+this.scoreAt(now))
+	if term != "" {
+		var termHits uint = 0
+		for t, count := range this.Terms {
+			if strings.HasPrefix(t, term) {
+				termHits += count
+			}
+		}
+		bonus += 15 * math.Log2(1+float64(termHits))
+	}
+	return maxBonus - uint(min(bonus, maxBonus))
+}
+
+func leastUsed(terms map[string]uint) string {
+	var result string
+	var fewest uint = math.MaxUint
+	for term, count := range terms {
+		if count < fewest || (count == fewest && term < result) {
+			result, fewest = term, count
+		}
+	}
+	return result
+}
+
+// Keeps the store from growing forever by dropping the lowest scoring paths
+func prune(now time.Time) {
+	if len(activations) <= maxPaths {
+		return
+	}
+	var paths = make([]string, 0, len(activations))
+	for path := range activations {
+		paths = append(paths, path)
+	}
+	slices.SortFunc(paths, func(p1, p2 string) int {
+		var s1, s2 = activations[p1].scoreAt(now), activations[p2].scoreAt(now)
+		if s1 < s2 {
+			return -1
+		} else if s1 > s2 {
+			return 1
+		} else {
+			return 0
+		}
+	})
+	for _, path := range paths[:len(paths)-maxPaths] {
+		delete(activations, path)
+	}
+}
+
+// Callers must hold activationsLock
+func load() {
+	if activations != nil {
+		return
+	}
+	activations = map[string]*Activations{}
+	if err := xdg.ReadJsonFile(frecencyPath, &activations); err != nil {
+		log.Print("Could not read ", frecencyPath, ": ", err)
+		activations = map[string]*Activations{}
+	}
+	for _, a := range activations {
+		if a.Terms == nil {
+			a.Terms = map[string]uint{}
+		}
+	}
+}
+
+// Callers must hold activationsLock
+func scheduleSave() {
+	if saveTimer == nil {
+		saveTimer = time.AfterFunc(saveDelay, save)
+	}
+}
+
+func save() {
+	activationsLock.Lock()
+	defer activationsLock.Unlock()
+	saveTimer = nil
+	if err := xdg.WriteJsonFile(frecencyPath, activations); err != nil {
+		log.Print("Could not write ", frecencyPath, ": ", err)
+	}
+}
+
+func GetFrecencyHandler() bind.Response {
+	activationsLock.Lock()
+	defer activationsLock.Unlock()
+	load()
+	return bind.Json(activations)
+}
+
+func DeleteFrecencyHandler(path string) bind.Response {
+	activationsLock.Lock()
+	defer activationsLock.Unlock()
+	load()
+	if path == "" {
+		activations = map[string]*Activations{}
+	} else if _, ok := activations[path]; !ok {
+		return bind.NotFound()
+	} else {
+		delete(activations, path)
+	}
+	scheduleSave()
+	return bind.Ok()
+}
@@ -0,0 +1,115 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package search
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestScoreAt(t *testing.T) {
+	var now = time.Now()
+	var tests = []struct {
+		score    float64
+		age      time.Duration
+		expected float64
+	}{
+		{4, 0, 4},
+		{4, halfLife, 2},
+		{4, 2 * halfLife, 1},
+		{4, halfLife / 2, 4 / math.Sqrt2},
+		{0, halfLife, 0},
+	}
+	for _, test := range tests {
+		var a = Activations{Score: test.score, Last: now.Add(-test.age)}
+		if score := a.scoreAt(now); math.Abs(score-test.expected) > 1e-9 {
+			t.Errorf("%v after %v: got %v, want %v", test.score, test.age, score, test.expected)
+		}
+	}
+}
+
+func TestPenalty(t *testing.T) {
+	var now = time.Now()
+	var tests = []struct {
+		score    float64
+		age      time.Duration
+		terms    map[string]uint
+		term     string
+		expected uint
+	}{
+		{1, 0, nil, "", 90},                               // 10*log2(1+1) off
+		{3, 0, nil, "", 80},                               // 10*log2(1+3)
+		{2, halfLife, nil, "", 90},                        // Decayed to 1
+		{0, 0, nil, "", maxBonus},                         // Nothing left
+		{1, 0, map[string]uint{"firefox": 3}, "fire", 60}, // And 15*log2(1+3) for the term
+		{1, 0, map[string]uint{"firefox": 3}, "firefox", 60},
+		{1, 0, map[string]uint{"firefox": 3}, "fox", 90}, // Terms must start with what is typed
+		{1, 0, map[string]uint{"firefox": 3}, "", 90},
+		{1, 0, map[string]uint{"firefox": 1, "fire": 2}, "fire", 60},
+		{1, 0, map[string]uint{"firefox": 1, "fire": 2}, "firef", 75},
+		{1<<20 - 1, 0, nil, "", 0}, // Bonus capped at maxBonus
+		{1<<20 - 1, 0, map[string]uint{"a": 1000}, "a", 0},
+	}
+	for _, test := range tests {
+		var a = Activations{Score: test.score, Last: now.Add(-test.age), Terms: test.terms}
+		if penalty := a.penalty(test.term, now); penalty != test.expected {
+			t.Errorf("score %v, age %v, terms %v, term '%s': got %d, want %d", test.score, test.age, test.terms, test.term, penalty, test.expected)
+		}
+	}
+}
+
+// More use, more recent use and use with the term typed all rank better
+func TestPenaltyOrder(t *testing.T) {
+	var now = time.Now()
+	var tests = []struct {
+		better Activations
+		worse  Activations
+		term   string
+	}{
+		{Activations{Score: 5, Last: now}, Activations{Score: 1, Last: now}, ""},
+		{Activations{Score: 2, Last: now}, Activations{Score: 2, Last: now.Add(-2 * halfLife)}, ""},
+		{Activations{Score: 1, Last: now, Terms: map[string]uint{"term": 1}}, Activations{Score: 1, Last: now}, "te"},
+		{Activations{Score: 1, Last: now, Terms: map[string]uint{"term": 1}}, Activations{Score: 3, Last: now, Terms: map[string]uint{"other": 5}}, "te"},
+	}
+	for i, test := range tests {
+		if better, worse := test.better.penalty(test.term, now), test.worse.penalty(test.term, now); better >= worse {
+			t.Errorf("%d: better got %d, worse %d", i, better, worse)
+		}
+	}
+}
+
+func TestPenalties(t *testing.T) {
+	activationsLock.Lock()
+	var saved = activations
+	activations = map[string]*Activations{
+		"/application/firefox.desktop": {Score: 1, Last: time.Now(), Terms: map[string]uint{"firefox": 3}},
+	}
+	activationsLock.Unlock()
+	defer func() {
+		activationsLock.Lock()
+		activations = saved
+		activationsLock.Unlock()
+	}()
+
+	// Terms are compared in lowercase. The score will have decayed the least bit, so 61 will do
+	var penalty = penalties("FIRE")
+	if p := penalty("/application/firefox.desktop"); p != 60 && p != 61 {
+		t.Errorf("got %d, want 60", p)
+	}
+	if p := penalty("/application/never-used.desktop"); p != maxBonus {
+		t.Errorf("got %d for a path never activated, want %d", p, maxBonus)
+	}
+}
+
+func TestLeastUsed(t *testing.T) {
+	if term := leastUsed(map[string]uint{"a": 3, "b": 1, "c": 2}); term != "b" {
+		t.Errorf("got '%s'", term)
+	}
+	if term := leastUsed(map[string]uint{"b": 1, "a": 1}); term != "a" { // Ties go by name, so the pick is stable
+		t.Errorf("got '%s'", term)
+	}
+}
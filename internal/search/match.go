@@ -5,10 +5,6 @@
 // Please refer to the GPL2 file for a copy of the license.
 package search
 
-import (
-	"strings"
-)
-
 /* Given a text (could be a resource title) and a term (what the user types to search for),
 * we look for shortest possible substrings containing the runes of term in the order the appear in term.
 * So if we search for 'abc'
@@ -46,6 +42,8 @@ import (
 *			10				   g		  [-1, -1]
 *
 *
+* Term and text are normalized first (see normalize.go), so positions and lengths are counted in runes of the
+* normalized text.
  */
 
 type matcher struct {
@@ -55,56 +53,100 @@ type matcher struct {
 }
 
 func makeMatcher(term string) matcher {
-	var m = matcher{term: []rune(strings.ToLower(term))}
-	if len(term) > 1 {
+	var m = matcher{term: normalize(term).runes}
+	if len(m.term) > 1 {
 		m.curstate = make([]int, len(m.term)-1)
 		m.nextstate = make([]int, len(m.term)-1)
 	}
 	return m
 }
 
-func (m matcher) match(text string) uint {
-	text = strings.ToLower(text)
+// A range of matched characters, [Start, End[, counted in runes of the (unnormalized) text
+type Range struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+/*
+* Returns the rank of text and the ranges of characters in text matching the term. For the match
+* the ranges are taken from the first occurrence of each term rune after the previous, which is
+* one way (of possibly several) to match term within that substring.
+*
+* text is normalized like term, and the rank counted in runes of the normalized text.
+ */
+func (m matcher) match(text string) (uint, []Range) {
+	var n = normalize(text)
 	switch len(m.term) {
 	case 0:
-		return 0
+		return 0, nil
 	case 1:
-		for pos, r := range text {
+		for pos, r := range n.runes {
 			if m.term[0] == r {
-				return uint(pos)
+				return uint(pos), m.ranges(n, pos)
 			}
 		}
-		return maxRank
+		return maxRank, nil
 	default:
 		var res = maxRank
+		var bestStart = -1
 
 		for i := range m.curstate {
 			m.curstate[i] = -1
 		}
 
-		for textpos, r := range text {
-			if r == m.term[0] {
-				m.nextstate[0] = textpos
-			} else {
-				m.nextstate[0] = m.curstate[0]
+		// Going from the back, so that a state advancing on r is not confused with the one before it doing so
+		for textpos, r := range n.runes {
+			var last = len(m.term) - 1
+			var consumed = m.term[last] == r && m.curstate[last-1] > -1
+			if consumed {
+				var start, end = m.curstate[last-1], textpos + 1
+				var tmp = uint(start + 5*(end-start-len(m.term)))
+				if tmp < res {
+					res, bestStart = tmp, start
+				}
 			}
-			for i := 1; i < len(m.term)-1; i++ {
-				if m.term[i] == r && m.curstate[i-1] > -1 {
-					m.nextstate[i], m.nextstate[i-1] = m.curstate[i-1], -1
+			for i := last - 1; i >= 1; i-- {
+				var advanced = m.term[i] == r && m.curstate[i-1] > -1
+				if advanced {
+					m.nextstate[i] = m.curstate[i-1]
+				} else if consumed {
+					m.nextstate[i] = -1
 				} else {
 					m.nextstate[i] = m.curstate[i]
 				}
+				consumed = advanced
 			}
-			if m.term[len(m.term)-1] == r && m.curstate[len(m.term)-2] > -1 {
-				var start, end = m.curstate[len(m.term)-2], textpos + 1
-				var tmp = uint(start + 5*(end-start-len(m.term)))
-				if tmp < res {
-					res = tmp
-				}
-				m.nextstate[len(m.term)-2] = -1
+			if m.term[0] == r {
+				m.nextstate[0] = textpos
+			} else if consumed {
+				m.nextstate[0] = -1
+			} else {
+				m.nextstate[0] = m.curstate[0]
 			}
 			m.curstate, m.nextstate = m.nextstate, m.curstate
 		}
-		return res
+		if bestStart < 0 {
+			return res, nil
+		}
+		return res, m.ranges(n, bestStart)
+	}
+}
+
+// Ranges, in the original text, of term runes matched left to right from start in n
+func (m matcher) ranges(n normalized, start int) []Range {
+	var ranges = []Range{}
+	var termpos = 0
+	for pos := start; pos < len(n.runes) && termpos < len(m.term); pos++ {
+		if n.runes[pos] != m.term[termpos] {
+			continue
+		}
+		termpos++
+		var origin = n.origin[pos]
+		if len(ranges) == 0 || ranges[len(ranges)-1].End < origin {
+			ranges = append(ranges, Range{origin, origin + 1})
+		} else if ranges[len(ranges)-1].End == origin {
+			ranges[len(ranges)-1].End++
+		} // else same original rune as the previous, eg. 'ß' when searching for 'ss'
 	}
+	return ranges
 }
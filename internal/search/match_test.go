@@ -0,0 +1,106 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package search
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	var tests = []struct {
+		term   string
+		text   string
+		rank   uint
+		ranges []Range
+	}{
+		// The examples from match.go
+		{"abc", "a very big cat", 45, []Range{{0, 1}, {7, 8}, {11, 12}}},
+		{"abc", "abcabcabc", 0, []Range{{0, 3}}},
+		{"abc", "aaaaabbbbcccc", 19, []Range{{4, 6}, {9, 10}}},
+		{"abc", "fgabhiabjkclmn", 16, []Range{{6, 8}, {10, 11}}},
+		{"abc", "fffababcggg", 5, []Range{{5, 8}}},
+		{"ab", "xxaxb", 7, []Range{{2, 3}, {4, 5}}},
+		{"b", "abc", 1, []Range{{1, 2}}},
+		{"", "abc", 0, nil},
+
+		// Case folding
+		{"FIREFOX", "firefox", 0, []Range{{0, 7}}},
+		{"firefox", "FireFox", 0, []Range{{0, 7}}},
+		{"σ", "ΟΔΥΣΣΕΥΣ", 3, []Range{{3, 4}}},
+		{"ς", "Σ", 0, []Range{{0, 1}}},
+
+		// Diacritics, ligatures and Foldings. Ranges are in runes of the original text
+		{"cafe", "Café", 0, []Range{{0, 4}}},
+		{"ecole", "École", 0, []Range{{0, 5}}},
+		{"ae", "Æble", 0, []Range{{0, 1}}},
+		{"aeble", "Æble", 0, []Range{{0, 4}}},
+		{"strasse", "Straße", 0, []Range{{0, 6}}},
+		{"ore", "Øresund", 0, []Range{{0, 3}}},
+		{"fire", "ﬁrefox", 0, []Range{{0, 3}}},
+		{"lodz", "Łódź", 0, []Range{{0, 4}}},
+		{"zs", "Łódź Street", 8, []Range{{3, 4}, {5, 6}}},
+		{"mu", "Ｍusic", 0, []Range{{0, 2}}},
+		{"é", "e", 0, []Range{{0, 1}}}, // The term is normalized too
+	}
+	for _, test := range tests {
+		var rank, ranges = makeMatcher(test.term).match(test.text)
+		if rank != test.rank || !slices.Equal(ranges, test.ranges) {
+			t.Errorf("'%s' in '%s': got %d, %v, want %d, %v", test.term, test.text, rank, ranges, test.rank, test.ranges)
+		}
+	}
+}
+
+func TestNoMatch(t *testing.T) {
+	var tests = []struct {
+		term string
+		text string
+	}{
+		{"abc", "acb"},
+		{"x", "abc"},
+		{"abc", ""},
+	}
+	for _, test := range tests {
+		if rank, ranges := makeMatcher(test.term).match(test.text); rank != maxRank || ranges != nil {
+			t.Errorf("'%s' in '%s': got %d, %v, want no match", test.term, test.text, rank, ranges)
+		}
+	}
+}
+
+// Matches at the start of a title rank best, then matches at word boundaries further in, then acronyms and other
+// matches with gaps
+func TestRankOrder(t *testing.T) {
+	var tests = []struct {
+		term   string
+		better string
+		worse  string
+	}{
+		{"term", "Terminal", "XTerm"},
+		{"code", "Code - OSS", "Visual Studio Code"},
+		{"code", "Visual Studio Code", "Visual Studio Community Edition"},
+		{"vsc", "VSCodium", "Visual Studio Code"},
+		{"gimp", "GIMP", "GNU Image Manipulation Program"},
+		{"ae", "Æble", "Mae West"},
+	}
+	for _, test := range tests {
+		var m = makeMatcher(test.term)
+		var betterRank, _ = m.match(test.better)
+		var worseRank, _ = m.match(test.worse)
+		if betterRank >= worseRank {
+			t.Errorf("'%s': '%s' ranked %d, '%s' %d", test.term, test.better, betterRank, test.worse, worseRank)
+		}
+	}
+}
+
+func TestNormalizeOrigin(t *testing.T) {
+	var n = normalize("Æß é")
+	if string(n.runes) != "aess e" {
+		t.Errorf("got '%s'", string(n.runes))
+	}
+	if !slices.Equal(n.origin, []int{0, 0, 1, 1, 2, 3}) {
+		t.Errorf("got %v", n.origin)
+	}
+}
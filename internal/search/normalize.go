@@ -0,0 +1,81 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package search
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+/*
+* Before matching, term and text are normalized: Letters are decomposed (NFKD) and combining marks dropped,
+* so 'é' becomes 'e' and 'ﬁ' becomes 'fi', then casefolded. Letters that don't decompose, but which users
+* commonly type in some other way, are handled by Foldings.
+*
+* This runs for every candidate on every keystroke, so the text is decomposed in one go, and what comes out
+* folded rune by rune without allocating.
+ */
+
+// Applied after casefolding. Keys must be lower case.
+var Foldings = map[rune]string{
+	'ß': "ss",
+	'æ': "ae",
+	'ø': "o",
+	'œ': "oe",
+	'đ': "d",
+	'ł': "l",
+	'þ': "th",
+}
+
+type normalized struct {
+	runes  []rune
+	origin []int // origin[i] is the index of the rune in the original text that runes[i] came from
+}
+
+func normalize(text string) normalized {
+	var n = normalized{runes: make([]rune, 0, len(text)), origin: make([]int, 0, len(text))}
+	var it norm.Iter
+	it.InitString(norm.NFKD, text)
+	var runeIndex, bytePos = 0, 0
+	for !it.Done() {
+		// A segment is a starter and the marks that go with it, coming from the original text at it.Pos()
+		var pos = it.Pos()
+		runeIndex += utf8.RuneCountInString(text[bytePos:pos])
+		bytePos = pos
+		var segment = it.Next()
+		for len(segment) > 0 {
+			var d, size = utf8.DecodeRune(segment)
+			segment = segment[size:]
+			if unicode.Is(unicode.Mn, d) {
+				continue
+			}
+			var folded = caseFold(d)
+			if replacement, ok := Foldings[folded]; ok {
+				for _, f := range replacement {
+					n.runes = append(n.runes, f)
+					n.origin = append(n.origin, runeIndex)
+				}
+			} else {
+				n.runes = append(n.runes, folded)
+				n.origin = append(n.origin, runeIndex)
+			}
+		}
+	}
+	return n
+}
+
+// Simple casefolding: Going through upper case takes eg. 'ς' and 'ſ' to 'σ' and 's'
+func caseFold(r rune) rune {
+	if r < utf8.RuneSelf {
+		if 'A' <= r && r <= 'Z' {
+			r += 'a' - 'A'
+		}
+		return r
+	}
+	return unicode.ToLower(unicode.ToUpper(r))
+}
@@ -0,0 +1,156 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package search
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/surlykke/refude/internal/lib/entity"
+	"github.com/surlykke/refude/internal/lib/xdg"
+)
+
+/*
+* A source of search results. Packages register their providers with Register, typically from an init function.
+*
+* Providers are consulted only when the search term has at least MinTermLength runes, and Weight is added to the
+* rank of each of their results, so a provider with a higher weight has its results further down the list.
+*
+* Both may be overridden in $XDG_CONFIG_HOME/refude/search.ini, with a group per provider, eg:
+*
+*    [bookmarks]
+*    MinTermLength=4
+*    Weight=50
+*
+*    [tabs]
+*    Enabled=false
+ */
+type Provider interface {
+	Name() string
+	MinTermLength() int
+	Weight() uint
+	Candidates(term string) []entity.Base
+}
+
+// A provider implementing this, returning true, is taken to answer the term, like a calculator would. It is given the
+// term as typed, not parsed as a query, as the query syntax would take parts of eg. an expression. Its candidates
+// are not matched against the term but put first in the result, as they are.
+type Answerer interface {
+	Answers() bool
+}
+
+// A provider implementing this may adjust the rank (lower is better) the matcher gives its results,
+// eg. to favour recently used ones.
+type Adjuster interface {
+	Adjust(b entity.Base, rank uint) uint
+}
+
+// A provider implementing this holds entities with children (see entity.Meta.Browsable), eg. directories. Children
+// returns the children of the entity at path, and false if the entity is not one of the provider's.
+type Browser interface {
+	Children(path string) ([]entity.Base, bool)
+}
+
+type searchable interface {
+	GetForSearch() []entity.Base
+}
+
+// A provider offering everything in m (an entity.EntityMap), leaving it to the matcher to filter
+func MapProvider(name string, minTermLength int, weight uint, m searchable) Provider {
+	return mapProvider{name: name, minTermLength: minTermLength, weight: weight, m: m}
+}
+
+type mapProvider struct {
+	name          string
+	minTermLength int
+	weight        uint
+	m             searchable
+}
+
+func (this mapProvider) Name() string                      { return this.name }
+func (this mapProvider) MinTermLength() int                { return this.minTermLength }
+func (this mapProvider) Weight() uint                      { return this.weight }
+func (this mapProvider) Candidates(_ string) []entity.Base { return this.m.GetForSearch() }
+
+// A provider with its settings, possibly overridden by configuration
+type configuredProvider struct {
+	Provider
+	minTermLength int
+	weight        uint
+}
+
+var providers []Provider
+var configured []configuredProvider
+var lookups []searchable
+var providersLock sync.Mutex
+var configPath = xdg.ConfigHome + "/refude/search.ini"
+
+func Register(provider Provider) {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+	providers = append(providers, provider)
+	configured = nil
+}
+
+// For entities not searched for, but which may be looked up by path (see SearchByPath), eg. mimetypes
+func RegisterLookup(m searchable) {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+	lookups = append(lookups, m)
+}
+
+func getLookups() []searchable {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+	return lookups
+}
+
+func getProviders() []configuredProvider {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+	if configured == nil {
+		configured = configure(providers)
+	}
+	return configured
+}
+
+func configure(providers []Provider) []configuredProvider {
+	var iniFile xdg.IniFile
+	if _, err := os.Stat(configPath); err == nil {
+		if iniFile, err = xdg.ReadIniFile(configPath); err != nil {
+			log.Print("Could not read ", configPath, ": ", err)
+		}
+	}
+
+	var result = make([]configuredProvider, 0, len(providers))
+	for _, p := range providers {
+		var cp = configuredProvider{Provider: p, minTermLength: p.MinTermLength(), weight: p.Weight()}
+		if group := iniFile.FindGroup(p.Name()); group != nil {
+			if enabled, ok := group.Entries["Enabled"]; ok && strings.ToLower(enabled) == "false" {
+				continue
+			}
+			if val, ok := group.Entries["MinTermLength"]; ok {
+				if minTermLength, err := strconv.Atoi(val); err != nil {
+					log.Print(configPath, ", ", p.Name(), ": bad MinTermLength: ", val)
+				} else {
+					cp.minTermLength = minTermLength
+				}
+			}
+			if val, ok := group.Entries["Weight"]; ok {
+				if weight, err := strconv.ParseUint(val, 10, 32); err != nil {
+					log.Print(configPath, ", ", p.Name(), ": bad Weight: ", val)
+				} else {
+					cp.weight = uint(weight)
+				}
+			}
+		}
+		result = append(result, cp)
+	}
+	return result
+}
@@ -0,0 +1,126 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package search
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+/*
+* What the user types is parsed as a query:
+*
+*   w:, a:, f:, t:, n:   At the start of a word: only search windows, applications, files, tabs or notifications.
+*                        May be given more than once.
+*   !word                Leave out results containing word
+*   "some words"         Only results containing 'some words'
+*   > rest               Pass rest as argument when activating the result. Only results accepting an argument are returned.
+*
+* Everything else is the (fuzzy) search term.
+*
+* Eg: 'a:fire > https://example.org'
+ */
+
+var providerPrefixes = map[string]string{
+	"w": "windows",
+	"a": "applications",
+	"f": "files",
+	"t": "tabs",
+	"n": "notifications",
+}
+
+type Query struct {
+	Term        string
+	Providers   []string // If non-empty, only search these
+	Phrases     []string
+	Excluded    []string
+	Argument    string
+	HasArgument bool
+}
+
+func ParseQuery(text string) Query {
+	var q Query
+	var words = []string{}
+	var runes = []rune(text)
+	var pos = 0
+	for pos < len(runes) {
+		if unicode.IsSpace(runes[pos]) {
+			pos++
+		} else if runes[pos] == '>' {
+			q.Argument, q.HasArgument = strings.TrimSpace(string(runes[pos+1:])), true
+			break
+		} else if runes[pos] == '"' {
+			var end = pos + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if phrase := strings.TrimSpace(string(runes[pos+1 : end])); phrase != "" {
+				q.Phrases = append(q.Phrases, phrase)
+			}
+			pos = end + 1
+		} else {
+			var end = pos
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && runes[end] != '>' && runes[end] != '"' {
+				end++
+			}
+			var word = string(runes[pos:end])
+			pos = end
+			if excluded, ok := strings.CutPrefix(word, "!"); ok {
+				if excluded != "" {
+					q.Excluded = append(q.Excluded, excluded)
+				}
+				continue
+			}
+			if prefix, rest, ok := strings.Cut(word, ":"); ok {
+				if provider, ok := providerPrefixes[prefix]; ok {
+					q.Providers = append(q.Providers, provider)
+					word = rest
+				}
+			}
+			if word != "" {
+				words = append(words, word)
+			}
+		}
+	}
+	q.Term = strings.Join(words, " ")
+	return q
+}
+
+func (this Query) wants(provider string) bool {
+	return len(this.Providers) == 0 || slices.Contains(this.Providers, provider)
+}
+
+// Checks phrases, exclusions and argument
+func (this Query) admits(r Ranked) bool {
+	if this.HasArgument && !r.Meta.AcceptsArgument {
+		return false
+	}
+	var texts = []string{string(normalize(r.Title).runes)}
+	for _, keyword := range r.Meta.Keywords {
+		texts = append(texts, string(normalize(keyword).runes))
+	}
+	var contains = func(s string) bool {
+		var normalized = string(normalize(s).runes)
+		for _, text := range texts {
+			if strings.Contains(text, normalized) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, phrase := range this.Phrases {
+		if !contains(phrase) {
+			return false
+		}
+	}
+	for _, excluded := range this.Excluded {
+		if contains(excluded) {
+			return false
+		}
+	}
+	return true
+}
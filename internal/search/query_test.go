@@ -0,0 +1,116 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package search
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/surlykke/refude/internal/lib/entity"
+)
+
+func TestParseQuery(t *testing.T) {
+	var tests = []struct {
+		text     string
+		expected Query
+	}{
+		{"", Query{Term: ""}},
+		{"   ", Query{Term: ""}},
+		{"firefox", Query{Term: "firefox"}},
+		{"  visual   studio ", Query{Term: "visual studio"}},
+
+		// Provider prefixes
+		{"a:fire", Query{Term: "fire", Providers: []string{"applications"}}},
+		{"w: fire", Query{Term: "fire", Providers: []string{"windows"}}},
+		{"w:term t:term", Query{Term: "term term", Providers: []string{"windows", "tabs"}}},
+		{"f:", Query{Term: "", Providers: []string{"files"}}},
+		{"x:fire", Query{Term: "x:fire"}}, // Not a prefix
+		{"fire a:", Query{Term: "fire", Providers: []string{"applications"}}},
+		{"http://example.org", Query{Term: "http://example.org"}},
+
+		// Exclusion
+		{"fire !fox", Query{Term: "fire", Excluded: []string{"fox"}}},
+		{"!a !b c", Query{Term: "c", Excluded: []string{"a", "b"}}},
+		{"fire !", Query{Term: "fire"}},
+		{"!a:fire", Query{Term: "", Excluded: []string{"a:fire"}}},
+
+		// Phrases
+		{`"some words"`, Query{Term: "", Phrases: []string{"some words"}}},
+		{`fire "web browser" x`, Query{Term: "fire x", Phrases: []string{"web browser"}}},
+		{`ab"cd"ef`, Query{Term: "ab ef", Phrases: []string{"cd"}}},
+		{`"unterminated phrase`, Query{Term: "", Phrases: []string{"unterminated phrase"}}},
+		{`""`, Query{Term: ""}},
+		{`"  "`, Query{Term: ""}},
+		{`"a > b"`, Query{Term: "", Phrases: []string{"a > b"}}}, // Quoted, '>' is just a character
+
+		// Argument
+		{"fire > https://example.org", Query{Term: "fire", Argument: "https://example.org", HasArgument: true}},
+		{"fire>x", Query{Term: "fire", Argument: "x", HasArgument: true}},
+		{"fire >", Query{Term: "fire", Argument: "", HasArgument: true}},
+		{`edit > "a b" !c a:d`, Query{Term: "edit", Argument: `"a b" !c a:d`, HasArgument: true}}, // Taken as is
+		{"> x", Query{Term: "", Argument: "x", HasArgument: true}},
+
+		// All together
+		{`a:fire !nightly "web" > https://example.org`, Query{
+			Term:        "fire",
+			Providers:   []string{"applications"},
+			Phrases:     []string{"web"},
+			Excluded:    []string{"nightly"},
+			Argument:    "https://example.org",
+			HasArgument: true,
+		}},
+	}
+	for _, test := range tests {
+		var q = ParseQuery(test.text)
+		if q.Term != test.expected.Term ||
+			!slices.Equal(q.Providers, test.expected.Providers) ||
+			!slices.Equal(q.Phrases, test.expected.Phrases) ||
+			!slices.Equal(q.Excluded, test.expected.Excluded) ||
+			q.Argument != test.expected.Argument ||
+			q.HasArgument != test.expected.HasArgument {
+			t.Errorf("ParseQuery(%s): got %+v, want %+v", test.text, q, test.expected)
+		}
+	}
+}
+
+func TestWants(t *testing.T) {
+	if q := ParseQuery("fire"); !q.wants("applications") || !q.wants("files") {
+		t.Error("a query without prefixes should want every provider")
+	}
+	if q := ParseQuery("a:fire w:x"); !q.wants("applications") || !q.wants("windows") || q.wants("files") {
+		t.Errorf("got %v", q.Providers)
+	}
+}
+
+func TestAdmits(t *testing.T) {
+	var ranked = func(title string, acceptsArgument bool, keywords ...string) Ranked {
+		var r = Ranked{Base: entity.Base{Title: title}}
+		r.Meta.Keywords = keywords
+		r.Meta.AcceptsArgument = acceptsArgument
+		return r
+	}
+	var tests = []struct {
+		text     string
+		r        Ranked
+		admitted bool
+	}{
+		{"fire", ranked("Firefox", false), true},
+		{`"web browser"`, ranked("Firefox", false, "Web Browser"), true},
+		{`"web browser"`, ranked("Firefox Web Browser", false), true},
+		{`"browser web"`, ranked("Firefox Web Browser", false), false},
+		{`"cafe"`, ranked("Café", false), true}, // Phrases are normalized, like the term
+		{"fire !nightly", ranked("Firefox Nightly", false), false},
+		{"fire !nightly", ranked("Firefox", false), true},
+		{"fire !beta", ranked("Firefox", false, "Beta"), false},
+		{"fire > x", ranked("Firefox", false), false},
+		{"fire > x", ranked("Firefox", true), true},
+	}
+	for _, test := range tests {
+		if admitted := ParseQuery(test.text).admits(test.r); admitted != test.admitted {
+			t.Errorf("'%s', %s: got %t, want %t", test.text, test.r.Title, admitted, test.admitted)
+		}
+	}
+}
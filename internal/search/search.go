@@ -9,15 +9,7 @@ import (
 	"slices"
 	"strings"
 
-	"github.com/surlykke/refude/internal/applications"
-	"github.com/surlykke/refude/internal/browser"
-	"github.com/surlykke/refude/internal/desktopactions"
-	"github.com/surlykke/refude/internal/file"
-	"github.com/surlykke/refude/internal/icons"
 	"github.com/surlykke/refude/internal/lib/entity"
-	"github.com/surlykke/refude/internal/notifications"
-	"github.com/surlykke/refude/internal/power"
-	"github.com/surlykke/refude/internal/wayland"
 	"github.com/surlykke/refude/pkg/bind"
 )
 
@@ -29,42 +21,63 @@ func GetHandler(term string) bind.Response {
 
 type Ranked struct {
 	entity.Base
-	Rank uint `json:"-"`
+	Rank          uint    `json:"-"`
+	TitleRanges   []Range `json:"titleRanges,omitempty"`   // Characters of title matching the search term
+	Keyword       string  `json:"keyword,omitempty"`       // The keyword matching, if that ranked better than title
+	KeywordRanges []Range `json:"keywordRanges,omitempty"` // Characters of keyword matching the search term
 }
 
+// term is parsed as a Query
 func Search(term string) []Ranked {
-	var m = makeMatcher(term)
+	var q = ParseQuery(term)
+	var m = makeMatcher(q.Term)
 	var result = make([]Ranked, 0, 1000)
-
-	result = append(result, filter(notifications.NotificationMap.GetForSearch(), m)...)
-	result = append(result, filter(wayland.WindowMap.GetForSearch(), m)...)
-	result = append(result, filter(browser.TabMap.GetForSearch(), m)...)
-
-	if len(m.term) > 0 {
-		result = append(result, filter(applications.AppMap.GetForSearch(), m)...)
+	var answers = []Ranked{}
+
+	for _, p := range getProviders() {
+		if answerer, ok := p.Provider.(Answerer); ok && answerer.Answers() {
+			if len(strings.TrimSpace(term)) >= p.minTermLength {
+				for _, b := range p.Candidates(term) {
+					answers = append(answers, Ranked{Base: b})
+				}
+			}
+		} else if !q.wants(p.Name()) || (len(q.Providers) == 0 && len(m.term) < p.minTermLength) {
+			// When the user asks for a provider, the term length doesn't matter
+			continue
+		} else {
+			var ranked = filter(p.Candidates(q.Term), m, p.weight)
+			if adjuster, ok := p.Provider.(Adjuster); ok {
+				for i := range ranked {
+					ranked[i].Rank = adjuster.Adjust(ranked[i].Base, ranked[i].Rank)
+				}
+			}
+			result = append(result, ranked...)
+		}
 	}
-	if len(m.term) > 2 {
-		result = append(result, filter(power.DeviceMap.GetForSearch(), m)...)
-		result = append(result, filter(file.FileMap.GetForSearch(), m)...)
-		result = append(result, filter(browser.BookmarkMap.GetForSearch(), m)...)
-		result = append(result, filter(desktopactions.PowerActions.GetForSearch(), m)...)
+	result = slices.DeleteFunc(result, func(r Ranked) bool { return !q.admits(r) })
+	var penalty = penalties(q.Term)
+	for i := range result {
+		result[i].Rank += penalty(result[i].Meta.Path)
 	}
 
 	sort(result)
-	return result
+	return append(answers, result...)
 }
 
-func filter(bases []entity.Base, m matcher) []Ranked {
+func filter(bases []entity.Base, m matcher, weight uint) []Ranked {
 	var result = make([]Ranked, 0, len(bases))
 	for _, res := range bases {
-		var rankCalculated = m.match(res.Title)
+		var rankCalculated, titleRanges = m.match(res.Title)
+		var ranked = Ranked{Base: res, TitleRanges: titleRanges}
 		for _, keyword := range res.Meta.Keywords {
-			if tmp := m.match(keyword) + 20; tmp < rankCalculated {
-				rankCalculated = tmp
+			if tmp, keywordRanges := m.match(keyword); tmp+20 < rankCalculated {
+				rankCalculated = tmp + 20
+				ranked.Keyword, ranked.KeywordRanges = keyword, keywordRanges
 			}
 		}
 		if rankCalculated < maxRank {
-			result = append(result, Ranked{Base: res, Rank: rankCalculated})
+			ranked.Rank = rankCalculated + weight
+			result = append(result, ranked)
 		}
 
 	}
@@ -83,33 +96,50 @@ func sort(list []Ranked) {
 
 }
 
+// Searches among the children of the entity at path, eg. the files of a directory. The term's provider prefixes
+// do not apply here, and frecency is not considered.
+func Browse(path string, term string) ([]Ranked, bool) {
+	var q = ParseQuery(term)
+	var m = makeMatcher(q.Term)
+	for _, p := range getProviders() {
+		if browser, ok := p.Provider.(Browser); ok {
+			if children, ok := browser.Children(path); ok {
+				var result = slices.DeleteFunc(filter(children, m, 0), func(r Ranked) bool { return !q.admits(r) })
+				sort(result)
+				return result, true
+			}
+		}
+	}
+	return nil, false
+}
+
 func SearchByPath(path string) (entity.Base, bool) {
-	var bases []entity.Base
-	if strings.HasPrefix(path, "/window/") {
-		bases = wayland.WindowMap.GetForSearch()
-	} else if strings.HasPrefix(path, "/application/") {
-		bases = applications.AppMap.GetForSearch()
-	} else if strings.HasPrefix(path, "/mimetype/") {
-		bases = applications.MimeMap.GetForSearch()
-	} else if strings.HasPrefix(path, "/notification/") {
-		bases = notifications.NotificationMap.GetForSearch()
-	} else if strings.HasPrefix(path, "/icontheme/") {
-		bases = icons.ThemeMap.GetForSearch()
-	} else if strings.HasPrefix(path, "/device/") {
-		bases = power.DeviceMap.GetForSearch()
-	} else if strings.HasPrefix(path, "/tab/") {
-		bases = browser.TabMap.GetForSearch()
-	} else if strings.HasPrefix(path, "/bookmark/") {
-		bases = browser.BookmarkMap.GetForSearch()
-	} else if strings.HasPrefix(path, "/file/") {
-		bases = file.FileMap.GetForSearch()
-	} else if strings.HasPrefix(path, "/start/") {
-		bases = desktopactions.PowerActions.GetForSearch()
+	for _, p := range getProviders() {
+		for _, b := range p.Candidates("") {
+			if b.Meta.Path == path {
+				return b, true
+			}
+		}
+	}
+	for _, m := range getLookups() {
+		for _, b := range m.GetForSearch() {
+			if b.Meta.Path == path {
+				return b, true
+			}
+		}
 	}
 
-	for _, b := range bases {
-		if b.Meta.Path == path {
-			return b, true
+	// It may be something browsed to
+	var parent = path[:max(strings.LastIndex(path, "/"), 0)]
+	for _, p := range getProviders() {
+		if browser, ok := p.Provider.(Browser); ok {
+			if children, ok := browser.Children(parent); ok {
+				for _, b := range children {
+					if b.Meta.Path == path {
+						return b, true
+					}
+				}
+			}
 		}
 	}
 
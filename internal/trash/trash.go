@@ -0,0 +1,212 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package trash
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/surlykke/refude/internal/lib/entity"
+	"github.com/surlykke/refude/internal/lib/xdg"
+	"github.com/surlykke/refude/pkg/bind"
+)
+
+/*
+* The users trash, as of the freedesktop.org Trash specification: Trashed files are moved to
+* $XDG_DATA_HOME/Trash/files, each with a <name>.trashinfo file in $XDG_DATA_HOME/Trash/info recording
+* where it came from and when.
+*
+* We only do the home trash, so files on other filesystems than $XDG_DATA_HOME can't be trashed.
+ */
+
+type TrashedFile struct {
+	entity.Base
+	Name         string // In the trash
+	OriginalPath string
+	DeletionDate time.Time
+}
+
+var TrashMap = entity.MakeMap[string, *TrashedFile]()
+
+var trashDir = xdg.DataHome + "/Trash"
+
+const dateLayout = "2006-01-02T15:04:05"
+
+var ErrOtherFilesystem = errors.New("not on the same filesystem as the trash")
+
+// Moves osPath to trash
+func Trash(osPath string) error {
+	osPath = filepath.Clean(osPath)
+	if _, err := os.Lstat(osPath); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(trashDir+"/files", 0700); err != nil {
+		return err
+	} else if err := os.MkdirAll(trashDir+"/info", 0700); err != nil {
+		return err
+	}
+
+	var name, infoFile, err = reserveName(filepath.Base(osPath))
+	if err != nil {
+		return err
+	}
+	var info = fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n", encodePath(osPath), time.Now().Format(dateLayout))
+	_, err = infoFile.WriteString(info)
+	infoFile.Close()
+	if err == nil {
+		err = os.Rename(osPath, trashDir+"/files/"+name)
+	}
+	if err != nil {
+		os.Remove(trashDir + "/info/" + name + ".trashinfo")
+		if errors.Is(err, syscall.EXDEV) {
+			return ErrOtherFilesystem
+		}
+		return err
+	}
+	load()
+	return nil
+}
+
+// Creating the info file exclusively, so no-one else gets the same name
+func reserveName(baseName string) (string, *os.File, error) {
+	for i := 1; ; i++ {
+		var name = baseName
+		if i > 1 {
+			name = baseName + "." + strconv.Itoa(i)
+		}
+		if _, err := os.Lstat(trashDir + "/files/" + name); err == nil {
+			continue
+		}
+		if file, err := os.OpenFile(trashDir+"/info/"+name+".trashinfo", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600); err == nil {
+			return name, file, nil
+		} else if !os.IsExist(err) {
+			return "", nil, err
+		}
+	}
+}
+
+// Percent-encode, as the spec says, but keep slashes
+func encodePath(osPath string) string {
+	var segments = strings.Split(osPath, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+func (this *TrashedFile) DoPost(action string) bind.Response {
+	if action != "" && action != "restore" {
+		return bind.NotFound()
+	} else if _, err := os.Lstat(this.OriginalPath); err == nil {
+		return bind.Conflict(errors.New(this.OriginalPath + " exists"))
+	} else if err := os.MkdirAll(filepath.Dir(this.OriginalPath), 0755); err != nil {
+		return bind.ServerError(err)
+	} else if err := os.Rename(trashDir+"/files/"+this.Name, this.OriginalPath); err != nil {
+		return bind.ServerError(err)
+	} else {
+		os.Remove(trashDir + "/info/" + this.Name + ".trashinfo")
+		load()
+		return bind.Accepted()
+	}
+}
+
+// Deletes permanently
+func (this *TrashedFile) DoDelete() bind.Response {
+	if err := this.remove(); err != nil {
+		return bind.ServerError(err)
+	}
+	load()
+	return bind.Accepted()
+}
+
+func (this *TrashedFile) remove() error {
+	if err := os.RemoveAll(trashDir + "/files/" + this.Name); err != nil {
+		return err
+	}
+	return os.Remove(trashDir + "/info/" + this.Name + ".trashinfo")
+}
+
+func EmptyHandler() bind.Response {
+	var errs []error
+	for _, tf := range TrashMap.GetAll() {
+		if err := tf.remove(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	load()
+	if len(errs) > 0 {
+		return bind.ServerError(errors.Join(errs...))
+	}
+	return bind.Accepted()
+}
+
+func Run() {
+	load()
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		panic(err)
+	}
+	os.MkdirAll(trashDir+"/info", 0700)
+	if err := watcher.Add(trashDir + "/info"); err != nil {
+		log.Print("Not watching trash: ", err)
+		return
+	}
+	for range watcher.Events {
+		load()
+	}
+}
+
+func load() {
+	var trashed = map[string]*TrashedFile{}
+	var entries, _ = os.ReadDir(trashDir + "/info")
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), ".trashinfo"); ok {
+			if tf, err := readTrashInfo(name); err != nil {
+				log.Print("Trash, ", name, ": ", err)
+			} else {
+				trashed[name] = tf
+			}
+		}
+	}
+	TrashMap.ReplaceAll(trashed)
+}
+
+func readTrashInfo(name string) (*TrashedFile, error) {
+	var iniFile, err = xdg.ReadIniFile(trashDir + "/info/" + name + ".trashinfo")
+	if err != nil {
+		return nil, err
+	}
+	var group = iniFile.FindGroup("Trash Info")
+	if group == nil {
+		return nil, errors.New("no 'Trash Info' group")
+	}
+	originalPath, err := url.PathUnescape(group.Entries["Path"])
+	if err != nil {
+		return nil, err
+	}
+	if !filepath.IsAbs(originalPath) {
+		return nil, errors.New("relative path in home trash")
+	}
+	var deletionDate, _ = time.ParseInLocation(dateLayout, group.Entries["DeletionDate"], time.Local)
+	var tf = &TrashedFile{
+		Base:         *entity.MakeBase(filepath.Base(originalPath), originalPath, "user-trash-full", "Trashed file"),
+		Name:         name,
+		OriginalPath: originalPath,
+		DeletionDate: deletionDate,
+	}
+	tf.AddAction("", "Restore", "edit-undo")
+	tf.AddDeleteAction("Delete permanently", "edit-delete")
+	return tf, nil
+}
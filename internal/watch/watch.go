@@ -6,38 +6,67 @@
 package watch
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/surlykke/refude/pkg/pubsub"
 )
 
 type event struct {
 	event string
+	path  string // Path of the resource the event concerns. Empty if not about a resource
 	data  string
 }
 
 var events = pubsub.MakePublisher[event]()
 
 func Publish(evt string, data string) {
-	events.Publish(event{evt, data})
+	events.Publish(event{event: evt, data: data})
 }
 
-func ResourceChanged(path string) {
-	Publish("resourceChanged", path)
+type ChangeKind string
+
+const (
+	Added   ChangeKind = "added"
+	Updated ChangeKind = "updated"
+	Removed ChangeKind = "removed"
+)
+
+type resourceChange struct {
+	Path string     `json:"path"`
+	Kind ChangeKind `json:"kind"`
+}
+
+func ResourceChanged(path string, kind ChangeKind) {
+	if data, err := json.Marshal(resourceChange{Path: path, Kind: kind}); err == nil {
+		events.Publish(event{event: "resourceChanged", path: path, data: string(data)})
+	}
 }
 
+/*
+* Events are sent as server-sent events. 'resourceChanged' has as data a json object with the path of the resource
+* and the kind of change, eg. '{"path":"/window/17","kind":"updated"}'. (It used to be just the path.)
+*
+* Clients may give one or more 'prefix' query parameters, eg. '/watch?prefix=/window/&prefix=/notification/'.
+* If so, resource events are only sent for paths starting with one of the prefixes. Events not about a resource,
+* eg. 'search', are always sent.
+ */
 func ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var prefixes = r.URL.Query()["prefix"]
 	var subscription = events.Subscribe()
 
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.(http.Flusher).Flush()
 
 	for {
 		var evt = subscription.Next()
+		if !wanted(evt, prefixes) {
+			continue
+		}
 		if _, err := fmt.Fprintf(w, "event:%s\n", evt.event); err != nil {
 			return
 		} else if _, err := fmt.Fprintf(w, "data:%s\n\n", evt.data); err != nil {
@@ -47,3 +76,15 @@ func ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 }
+
+func wanted(evt event, prefixes []string) bool {
+	if len(prefixes) == 0 || evt.path == "" {
+		return true
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(evt.path, prefix) {
+			return true
+		}
+	}
+	return false
+}
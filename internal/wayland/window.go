@@ -12,12 +12,17 @@ import (
 
 	"github.com/surlykke/refude/internal/applications"
 	"github.com/surlykke/refude/internal/lib/entity"
+	"github.com/surlykke/refude/internal/search"
 	"github.com/surlykke/refude/internal/watch"
 	"github.com/surlykke/refude/pkg/bind"
 )
 
 var WindowMap = entity.MakeMap[uint64, *WaylandWindow]()
 
+func init() {
+	search.Register(search.MapProvider("windows", 0, 0, WindowMap))
+}
+
 var windowUpdates = make(chan windowUpdate)
 var removals = make(chan uint64)
 var ignoredWindows map[string]bool
@@ -138,6 +143,10 @@ func (wsm WindowStateMask) MarshalJSON() ([]byte, error) {
 	return json.Marshal(wsm.toStringList())
 }
 
+func (wsm WindowStateMask) JSONSchema() map[string]any {
+	return map[string]any{"type": "array", "items": map[string]any{"type": "string", "enum": []string{"MAXIMIZED", "MINIMIZED", "ACTIVATED", "FULLSCREEN"}}}
+}
+
 type WaylandWindow struct {
 	entity.Base
 	Wid   uint64 `json:"-"`
@@ -153,7 +162,7 @@ func makeWindow(wId uint64, title string, iconName string, appId string, state W
 		State: state,
 	}
 	ww.AddAction("", "Focus", "")
-	//ww.AddAction("close", title, "Close window", "window-close")
+	ww.AddDeleteAction("Close", "window-close")
 	return ww
 }
 
@@ -9,6 +9,9 @@ const (
 	query uint8 = iota
 	path
 	body
+	header
+	cookie
+	queryParams
 )
 
 type binding struct {
@@ -18,6 +21,7 @@ type binding struct {
 	defaultValue string
 }
 
+// If the function parameter is a slice, all occurrences of the query parameter are collected
 func Query(queryParameter string) binding {
 	return binding{kind: query, qualifier: queryParameter}
 }
@@ -26,10 +30,44 @@ func QueryOr(queryParameter string, defaultValue string) binding {
 	return binding{kind: query, qualifier: queryParameter, optional: true, defaultValue: defaultValue}
 }
 
+// Binds all query parameters. The function parameter must be of type url.Values
+func QueryParams() binding {
+	return binding{kind: queryParams}
+}
+
 func Path(pathParameter string) binding {
 	return binding{kind: path, qualifier: pathParameter}
 }
 
+func Header(headerName string) binding {
+	return binding{kind: header, qualifier: headerName}
+}
+
+func HeaderOr(headerName string, defaultValue string) binding {
+	return binding{kind: header, qualifier: headerName, optional: true, defaultValue: defaultValue}
+}
+
+func Cookie(cookieName string) binding {
+	return binding{kind: cookie, qualifier: cookieName}
+}
+
+func CookieOr(cookieName string, defaultValue string) binding {
+	return binding{kind: cookie, qualifier: cookieName, optional: true, defaultValue: defaultValue}
+}
+
+/*
+* Binds the request body. bodyType is one of:
+*   "json": body must have Content-Type application/json and is unmarshalled into the parameter
+*   "form": body must have Content-Type application/x-www-form-urlencoded or multipart/form-data. The
+*           parameter must be a url.Values or a struct, in which case fields are set from form values named by
+*           their 'form' tag (or, if no tag, the field name). A tag of "-" skips the field.
+* Requests with another Content-Type are rejected with 415, bodies larger than MaxBodySize with 413.
+ */
 func Body(bodyType string) binding {
-	return binding{kind: path, qualifier: bodyType}
+	return binding{kind: body, qualifier: bodyType}
+}
+
+// Like Body, but a request without a body (Content-Length 0) gives the parameter its zero value
+func BodyOr(bodyType string) binding {
+	return binding{kind: body, qualifier: bodyType, optional: true}
 }
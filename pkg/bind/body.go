@@ -0,0 +1,146 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package bind
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+)
+
+var MaxBodySize int64 = 1 << 20
+
+// An error which, when returned from a deserializer, makes the adapter respond with the contained response
+type responseError struct {
+	response Response
+	msg      string
+}
+
+func (this *responseError) Error() string {
+	return this.msg
+}
+
+func makeBodyDeserializer(bodyType string, _type reflect.Type) (deserializer, error) {
+	switch bodyType {
+	case "json":
+		return func(r *http.Request) (reflect.Value, error) {
+			if err := checkContentType(r, "application/json"); err != nil {
+				return reflect.Value{}, err
+			}
+			var valPtr = reflect.New(_type)
+			var decoder = json.NewDecoder(http.MaxBytesReader(nil, r.Body, MaxBodySize))
+			if err := decoder.Decode(valPtr.Interface()); err != nil {
+				return reflect.Value{}, bodyReadError(err)
+			}
+			return valPtr.Elem(), nil
+		}, nil
+	case "form":
+		var formDecoder, err = makeFormDecoder(_type)
+		if err != nil {
+			return nil, err
+		}
+		return func(r *http.Request) (reflect.Value, error) {
+			if err := checkContentType(r, "application/x-www-form-urlencoded", "multipart/form-data"); err != nil {
+				return reflect.Value{}, err
+			}
+			if values, err := readForm(r); err != nil {
+				return reflect.Value{}, bodyReadError(err)
+			} else {
+				return formDecoder(values)
+			}
+		}, nil
+	default:
+		return nil, errors.New("Unrecognized body type: " + bodyType + ". Only 'json' and 'form' are supported")
+	}
+}
+
+func checkContentType(r *http.Request, acceptedTypes ...string) error {
+	var mediaType, _, _ = mime.ParseMediaType(r.Header.Get("Content-Type"))
+	for _, acceptedType := range acceptedTypes {
+		if mediaType == acceptedType {
+			return nil
+		}
+	}
+	return &responseError{UnsupportedMediaType(), fmt.Sprintf("Content-Type '%s' not supported", mediaType)}
+}
+
+func bodyReadError(err error) error {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return &responseError{RequestEntityTooLarge(), err.Error()}
+	}
+	return err
+}
+
+// Only body values, not query parameters
+func readForm(r *http.Request) (url.Values, error) {
+	r.Body = http.MaxBytesReader(nil, r.Body, MaxBodySize)
+	var mediaType, _, _ = mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		if err := r.ParseMultipartForm(MaxBodySize); err != nil {
+			return nil, err
+		}
+		return url.Values(r.MultipartForm.Value), nil
+	} else if err := r.ParseForm(); err != nil {
+		return nil, err
+	} else {
+		return r.PostForm, nil
+	}
+}
+
+type formDecoder func(values url.Values) (reflect.Value, error)
+
+func makeFormDecoder(_type reflect.Type) (formDecoder, error) {
+	if _type == reflect.TypeOf(url.Values{}) {
+		return func(values url.Values) (reflect.Value, error) {
+			return reflect.ValueOf(values), nil
+		}, nil
+	} else if _type.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("form body must bind to url.Values or a struct, not %s", _type)
+	}
+
+	type fieldDecoder struct {
+		index int
+		name  string
+		conv  valuesConverter
+	}
+
+	var fieldDecoders = make([]fieldDecoder, 0, _type.NumField())
+	for i := 0; i < _type.NumField(); i++ {
+		var field = _type.Field(i)
+		var name = field.Tag.Get("form")
+		if name == "-" || !field.IsExported() {
+			continue
+		} else if name == "" {
+			name = field.Name
+		}
+		if conv, err := getValuesConverter(field.Type); err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		} else {
+			fieldDecoders = append(fieldDecoders, fieldDecoder{index: i, name: name, conv: conv})
+		}
+	}
+
+	return func(values url.Values) (reflect.Value, error) {
+		var val = reflect.New(_type).Elem()
+		var errs = []error{}
+		for _, fd := range fieldDecoders {
+			if !values.Has(fd.name) {
+				continue
+			}
+			if fieldVal, err := fd.conv(values[fd.name]); err != nil {
+				errs = append(errs, fmt.Errorf("form value '%s': %w", fd.name, err))
+			} else {
+				val.Field(fd.index).Set(fieldVal)
+			}
+		}
+		return val, errors.Join(errs...)
+	}, nil
+}
@@ -6,11 +6,10 @@
 package bind
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"net/url"
 	"reflect"
 )
 
@@ -55,6 +54,12 @@ func MakeAdapter(function any, bindings ...binding) (func(r *http.Request) Respo
 				}
 			}
 			if len(errs) > 0 {
+				for _, err := range errs {
+					var re *responseError
+					if errors.As(err, &re) {
+						return re.response
+					}
+				}
 				fmt.Println("Return unprocessable entity", errs)
 				return UnprocessableEntity(errors.Join(errs...))
 			} else {
@@ -83,43 +88,74 @@ type deserializer func(r *http.Request) (reflect.Value, error)
 
 func makeDeserializer(b binding, _type reflect.Type) (deserializer, error) {
 	if b.kind == body {
-		if b.qualifier == "json" {
-			return func(r *http.Request) (reflect.Value, error) {
-				var valPtr = reflect.New(_type)
-				if bytes, err := io.ReadAll(r.Body); err != nil {
-					return reflect.Value{}, err
-				} else {
-					err = json.Unmarshal(bytes, valPtr.Interface())
-					return valPtr.Elem(), err
-				}
-			}, nil
-		} else {
-			return nil, errors.New("Unrecognized body type: " + b.qualifier + ". Only 'json' is supported")
+		var bodyDeserializer, err = makeBodyDeserializer(b.qualifier, _type)
+		if err != nil || !b.optional {
+			return bodyDeserializer, err
 		}
+		return func(r *http.Request) (reflect.Value, error) {
+			if r.ContentLength == 0 {
+				return reflect.Zero(_type), nil
+			}
+			return bodyDeserializer(r)
+		}, nil
+	} else if b.kind == queryParams {
+		if _type != reflect.TypeOf(url.Values{}) {
+			return nil, errors.New("QueryParams must bind to a parameter of type url.Values")
+		}
+		return func(r *http.Request) (reflect.Value, error) {
+			return reflect.ValueOf(r.URL.Query()), nil
+		}, nil
 	} else {
-		var conv, err = getConverter(_type)
+		var conv, err = getValuesConverter(_type)
 		if err != nil {
 			return nil, err
 		}
-		if b.kind == query {
-			return func(r *http.Request) (reflect.Value, error) {
-				var val string
-				if r.URL.Query().Has(b.qualifier) {
-					val = r.URL.Query()[b.qualifier][0] // TODO: lists
-				} else if !b.optional {
-					return reflect.Value{}, errors.New("query parameter '" + b.qualifier + "' required and not given")
-				} else {
-					val = b.defaultValue
+		return func(r *http.Request) (reflect.Value, error) {
+			var vals = b.values(r)
+			if len(vals) == 0 {
+				if !b.optional {
+					return reflect.Value{}, errors.New(b.describe() + " required and not given")
+				} else if b.defaultValue != "" || _type.Kind() != reflect.Slice {
+					vals = []string{b.defaultValue}
 				}
-				return conv(val)
-			}, nil
-		} else if b.kind == path {
-			return func(r *http.Request) (reflect.Value, error) {
-				return conv(r.PathValue(b.qualifier))
-			}, nil
-		} else {
-			panic("Should not happen")
+			}
+			return conv(vals)
+		}, nil
+	}
+}
+
+// The (string) values given in the request for b
+func (b binding) values(r *http.Request) []string {
+	switch b.kind {
+	case query:
+		return r.URL.Query()[b.qualifier]
+	case path:
+		return []string{r.PathValue(b.qualifier)}
+	case header:
+		return r.Header.Values(b.qualifier)
+	case cookie:
+		var vals []string
+		for _, c := range r.CookiesNamed(b.qualifier) {
+			vals = append(vals, c.Value)
 		}
+		return vals
+	default:
+		panic("Should not happen")
+	}
+}
+
+func (b binding) describe() string {
+	switch b.kind {
+	case query:
+		return "query parameter '" + b.qualifier + "'"
+	case path:
+		return "path parameter '" + b.qualifier + "'"
+	case header:
+		return "header '" + b.qualifier + "'"
+	case cookie:
+		return "cookie '" + b.qualifier + "'"
+	default:
+		return "'" + b.qualifier + "'"
 	}
 }
 
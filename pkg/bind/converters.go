@@ -6,14 +6,75 @@
 package bind
 
 import (
+	"encoding"
 	"fmt"
 	"reflect"
 	"strconv"
+	"time"
 )
 
 type converter func(paramVal string) (reflect.Value, error)
 
+// Converts all values given for a parameter
+type valuesConverter func(paramVals []string) (reflect.Value, error)
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+var durationType = reflect.TypeOf(time.Duration(0))
+
+/*
+* Slices (that do not themselves implement encoding.TextUnmarshaler) get an element for each value given,
+* other types are converted from the first value.
+ */
+func getValuesConverter(t reflect.Type) (valuesConverter, error) {
+	if t.Kind() == reflect.Slice && !reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		if elemConv, err := getConverter(t.Elem()); err != nil {
+			return nil, err
+		} else {
+			return func(paramVals []string) (reflect.Value, error) {
+				var slice = reflect.MakeSlice(t, len(paramVals), len(paramVals))
+				for i, paramVal := range paramVals {
+					if elemVal, err := elemConv(paramVal); err != nil {
+						return reflect.Value{}, err
+					} else {
+						slice.Index(i).Set(elemVal)
+					}
+				}
+				return slice, nil
+			}, nil
+		}
+	} else if conv, err := getConverter(t); err != nil {
+		return nil, err
+	} else {
+		return func(paramVals []string) (reflect.Value, error) {
+			return conv(paramVals[0])
+		}, nil
+	}
+}
+
+// Returned converter yields values of type t
 func getConverter(t reflect.Type) (converter, error) {
+	if t == durationType {
+		return toDuration, nil
+	} else if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return func(paramVal string) (reflect.Value, error) {
+			var valPtr = reflect.New(t)
+			var err = valPtr.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(paramVal))
+			return valPtr.Elem(), err
+		}, nil
+	} else if conv, err := getKindConverter(t); err != nil {
+		return nil, err
+	} else {
+		return func(paramVal string) (reflect.Value, error) {
+			var val, err = conv(paramVal)
+			if err == nil && val.Type() != t {
+				val = val.Convert(t) // Named types, eg. 'type Urgency uint8'
+			}
+			return val, err
+		}, nil
+	}
+}
+
+func getKindConverter(t reflect.Type) (converter, error) {
 	switch t.Kind() {
 	case reflect.Bool:
 		return toBool, nil
@@ -115,3 +176,8 @@ func toFloat64(paramVal string) (reflect.Value, error) {
 func toString(paramVal string) (reflect.Value, error) {
 	return reflect.ValueOf(paramVal), nil
 }
+
+func toDuration(paramVal string) (reflect.Value, error) {
+	var d, err = time.ParseDuration(paramVal)
+	return reflect.ValueOf(d), err
+}
@@ -0,0 +1,33 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package bind
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+// Makes a (strong) entity tag from a response body
+func ETag(body []byte) string {
+	var hash = fnv.New64a()
+	hash.Write(body)
+	return fmt.Sprintf(`"%x"`, hash.Sum64())
+}
+
+/*
+* Checks the value of an If-Match or If-None-Match header against etag.
+* headerValue may be '*' or a comma separated list of (possibly weak) entity tags
+ */
+func ETagMatches(headerValue string, etag string) bool {
+	for _, candidate := range strings.Split(headerValue, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package bind
+
+import (
+	"strconv"
+	"strings"
+)
+
+/*
+* Returns the media type among offered that the Accept header value accept prefers, or "" if none
+* is acceptable. Where accept rates more than one equally, the one offered first is chosen. An empty accept
+* means anything goes.
+ */
+func Negotiate(accept string, offered ...string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offered[0]
+	}
+
+	var best, bestQ = "", 0.0
+	for _, mediaType := range offered {
+		if q := acceptQuality(accept, mediaType); q > bestQ {
+			best, bestQ = mediaType, q
+		}
+	}
+	return best
+}
+
+// The quality given to mediaType by the most specific matching media range in accept
+func acceptQuality(accept string, mediaType string) float64 {
+	var q, specificity = 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		var params = strings.Split(part, ";")
+		var mediaRange = strings.ToLower(strings.TrimSpace(params[0]))
+		var rangeQ = 1.0
+		for _, param := range params[1:] {
+			if name, value, ok := strings.Cut(strings.TrimSpace(param), "="); ok && strings.TrimSpace(name) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					rangeQ = parsed
+				}
+			}
+		}
+
+		var rangeSpecificity int
+		if mediaRange == mediaType {
+			rangeSpecificity = 2
+		} else if mainType, subType, _ := strings.Cut(mediaRange, "/"); subType == "*" && strings.HasPrefix(mediaType, mainType+"/") {
+			rangeSpecificity = 1
+		} else if mediaRange == "*/*" || mediaRange == "*" {
+			rangeSpecificity = 0
+		} else {
+			continue
+		}
+		if rangeSpecificity > specificity {
+			q, specificity = rangeQ, rangeSpecificity
+		}
+	}
+	return q
+}
@@ -0,0 +1,268 @@
+// Copyright (c) Christian Surlykke
+//
+// This file is part of the refude project.
+// It is distributed under the GPL v2 license.
+// Please refer to the GPL2 file for a copy of the license.
+package bind
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+* Routes registered with Handle or HandleHttp are served by http.DefaultServeMux and described by OpenAPI.
+ */
+
+type Route struct {
+	method       string
+	path         string
+	bindings     []binding
+	paramTypes   []reflect.Type
+	responseType reflect.Type
+	summary      string
+}
+
+var routes []*Route
+var routesLock sync.Mutex
+
+func Handle(pattern string, function any, bindings ...binding) *Route {
+	var route = makeRoute(pattern)
+	route.bindings = bindings
+	var funcType = reflect.TypeOf(function)
+	for i := 0; i < funcType.NumIn() && i < len(bindings); i++ {
+		route.paramTypes = append(route.paramTypes, funcType.In(i))
+	}
+	http.Handle(pattern, HandlerFunc(function, bindings...))
+	return route
+}
+
+// For handlers not made from a function and bindings.
+func HandleHttp(pattern string, handler http.Handler) *Route {
+	var route = makeRoute(pattern)
+	http.Handle(pattern, handler)
+	return route
+}
+
+// Set the type of the json the route responds with, by giving a value of that type. Eg: Returns([]string{})
+func (this *Route) Returns(example any) *Route {
+	this.responseType = reflect.TypeOf(example)
+	return this
+}
+
+func (this *Route) Summary(summary string) *Route {
+	this.summary = summary
+	return this
+}
+
+func makeRoute(pattern string) *Route {
+	var route = &Route{method: "GET", path: pattern}
+	if method, path, found := strings.Cut(pattern, " "); found {
+		route.method, route.path = method, strings.TrimSpace(path)
+	}
+	routesLock.Lock()
+	defer routesLock.Unlock()
+	routes = append(routes, route)
+	return route
+}
+
+// Entity types may implement this to describe their json form, when it is not derivable from their go type
+// (eg. because they implement json.Marshaler)
+type SchemaProvider interface {
+	JSONSchema() map[string]any
+}
+
+var wildcard = regexp.MustCompile(`\{([^}.]*)(\.\.\.)?\}`)
+
+func OpenAPI() Response {
+	routesLock.Lock()
+	defer routesLock.Unlock()
+
+	var builder = schemaBuilder{components: map[string]any{}}
+	var paths = map[string]map[string]any{}
+	for _, route := range routes {
+		var path = strings.TrimSuffix(route.path, "{$}")
+		path = wildcard.ReplaceAllString(path, "{$1}")
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(route.method)] = builder.operation(route)
+	}
+
+	return Json(map[string]any{
+		"openapi": "3.0.3",
+		"info":    map[string]any{"title": "refude", "version": "1"},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": builder.components,
+		},
+	})
+}
+
+func (this *schemaBuilder) operation(route *Route) map[string]any {
+	var operation = map[string]any{}
+	if route.summary != "" {
+		operation["summary"] = route.summary
+	}
+
+	var parameters = []map[string]any{}
+	for i, b := range route.bindings {
+		var schema = map[string]any{}
+		if i < len(route.paramTypes) {
+			schema = this.schemaOf(route.paramTypes[i])
+			if b.optional && b.defaultValue != "" {
+				schema = withDefault(schema, route.paramTypes[i], b.defaultValue)
+			}
+		}
+		switch b.kind {
+		case query, path, header, cookie:
+			var in = map[uint8]string{query: "query", path: "path", header: "header", cookie: "cookie"}[b.kind]
+			var param = map[string]any{"name": b.qualifier, "in": in, "required": b.kind == path || !b.optional, "schema": schema}
+			if schema["type"] == "array" {
+				param["explode"] = true
+			}
+			parameters = append(parameters, param)
+		case body:
+			var contentTypes = []string{"application/json"}
+			if b.qualifier == "form" {
+				contentTypes = []string{"application/x-www-form-urlencoded", "multipart/form-data"}
+			}
+			var content = map[string]any{}
+			for _, contentType := range contentTypes {
+				content[contentType] = map[string]any{"schema": schema}
+			}
+			operation["requestBody"] = map[string]any{"required": !b.optional, "content": content}
+		}
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+
+	var okResponse = map[string]any{"description": "OK"}
+	if route.responseType != nil {
+		okResponse["content"] = map[string]any{"application/json": map[string]any{"schema": this.schemaOf(route.responseType)}}
+	}
+	operation["responses"] = map[string]any{"default": okResponse}
+	return operation
+}
+
+func withDefault(schema map[string]any, t reflect.Type, defaultValue string) map[string]any {
+	var copy = make(map[string]any, len(schema)+1)
+	for k, v := range schema {
+		copy[k] = v
+	}
+	copy["default"] = defaultValue
+	if schema["type"] == "integer" || schema["type"] == "number" || schema["type"] == "boolean" {
+		if conv, err := getConverter(t); err == nil {
+			if val, err := conv(defaultValue); err == nil {
+				copy["default"] = val.Interface()
+			}
+		}
+	}
+	return copy
+}
+
+type schemaBuilder struct {
+	components map[string]any
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+var schemaProviderType = reflect.TypeOf((*SchemaProvider)(nil)).Elem()
+
+func (this *schemaBuilder) schemaOf(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if reflect.PointerTo(t).Implements(schemaProviderType) {
+		return reflect.New(t).Interface().(SchemaProvider).JSONSchema()
+	} else if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	} else if t == durationType {
+		return map[string]any{"type": "string", "example": "1m30s"}
+	} else if reflect.PointerTo(t).Implements(jsonMarshalerType) {
+		return map[string]any{} // Can't tell
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": this.schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": this.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return this.structSchema(t)
+		}
+		var name = t.Name()
+		if _, ok := this.components[name]; !ok {
+			this.components[name] = map[string]any{} // Placeholder, in case of recursive types
+			this.components[name] = this.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]any{}
+	}
+}
+
+func (this *schemaBuilder) structSchema(t reflect.Type) map[string]any {
+	var properties = map[string]any{}
+	var required = []string{}
+	this.collectProperties(t, properties, &required)
+	var schema = map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		slices.Sort(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+// Follows the rules of encoding/json (mostly), so embedded structs have their fields promoted
+func (this *schemaBuilder) collectProperties(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		var field = t.Field(i)
+		var tag = field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		var name, options, _ = strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			var fieldType = field.Type
+			if fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				this.collectProperties(fieldType, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = this.schemaOf(field.Type)
+		if !slices.Contains(strings.Split(options, ","), "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
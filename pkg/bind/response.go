@@ -31,6 +31,17 @@ func (this Response) Send(w http.ResponseWriter) {
 	w.Write(this.Body)
 }
 
+// Returns a copy of the response with the header set
+func (this Response) WithHeader(name string, value string) Response {
+	var headers = this.Headers.Clone()
+	if headers == nil {
+		headers = http.Header{}
+	}
+	headers.Set(name, value)
+	this.Headers = headers
+	return this
+}
+
 func Ok() Response {
 	return Response{Status: http.StatusOK}
 }
@@ -51,6 +62,10 @@ func ServerError(err error) Response {
 	return Response{Status: http.StatusInternalServerError, Body: []byte(err.Error())}
 }
 
+func Conflict(err error) Response {
+	return Response{Status: http.StatusConflict, Body: []byte(err.Error())}
+}
+
 func Accepted() Response {
 	return Response{Status: http.StatusAccepted}
 }
@@ -63,6 +78,18 @@ func PreconditionFailed() Response {
 	return Response{Status: http.StatusPreconditionFailed}
 }
 
+func NotAcceptable() Response {
+	return Response{Status: http.StatusNotAcceptable}
+}
+
+func UnsupportedMediaType() Response {
+	return Response{Status: http.StatusUnsupportedMediaType}
+}
+
+func RequestEntityTooLarge() Response {
+	return Response{Status: http.StatusRequestEntityTooLarge}
+}
+
 func Json(data any) Response {
 	return Response{Status: http.StatusOK, Headers: http.Header{"Content-Type": {"application/json"}}, Body: ToJson(data)}
 }